@@ -0,0 +1,213 @@
+package gopush
+
+import (
+	"container/list"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sync"
+)
+
+// EvaluationKey identifies the evaluation of a program on a set of fitness
+// cases with a given configuration of the Interpreter.
+type EvaluationKey struct {
+	Program uint64
+	Cases   uint64
+	Options uint64
+}
+
+// NewEvaluationKey returns the key of evaluating program on cases with
+// Interpreters created from options. The program is identified by its Hash
+// and the options by their String form, so two evaluations only share a key
+// if they use the same RandomSeed. Fitness cases are identified by their type
+// and their %v formatting, so cases whose formatting is not stable, e.g.
+// because they contain pointers, should implement fmt.Stringer.
+func NewEvaluationKey(program Code, cases []FitnessCase, options Options) EvaluationKey {
+	return EvaluationKey{
+		Program: program.Hash(),
+		Cases:   casesHash(cases),
+		Options: hashString(options.String()),
+	}
+}
+
+func casesHash(cases []FitnessCase) uint64 {
+	h := fnv.New64a()
+	for _, fc := range cases {
+		fmt.Fprintf(h, "%T %v\n", fc, fc)
+	}
+	return h.Sum64()
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// EvaluationCache remembers the error vectors of evaluated programs, so that
+// programs that appear again, e.g. because they were reproduced into the next
+// generation, are not evaluated again. It holds up to a fixed number of
+// entries and evicts the least recently used entry when it is full. It is
+// safe for concurrent use.
+type EvaluationCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[EvaluationKey]*list.Element
+	order    *list.List // most recently used first
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type cacheEntry struct {
+	Key    EvaluationKey
+	Errors []float64
+}
+
+// NewEvaluationCache returns an empty cache that holds up to capacity error
+// vectors.
+func NewEvaluationCache(capacity int) *EvaluationCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &EvaluationCache{
+		capacity: capacity,
+		entries:  make(map[EvaluationKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns a copy of the error vector stored under key, and whether there
+// was one. Every call counts as a hit or a miss in the statistics.
+func (c *EvaluationCache) Get(key EvaluationKey) ([]float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	c.order.MoveToFront(e)
+
+	return append([]float64(nil), e.Value.(*cacheEntry).Errors...), true
+}
+
+// countHits counts n hits that did not need a lookup.
+func (c *EvaluationCache) countHits(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hits += int64(n)
+}
+
+// Put stores a copy of the error vector under key.
+func (c *EvaluationCache) Put(key EvaluationKey, errors []float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.put(key, append([]float64(nil), errors...))
+}
+
+func (c *EvaluationCache) put(key EvaluationKey, errors []float64) {
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*cacheEntry).Errors = errors
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{Key: key, Errors: errors})
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).Key)
+		c.evictions++
+	}
+}
+
+// CacheStats are the statistics of an EvaluationCache.
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Size      int
+}
+
+// HitRate returns the fraction of lookups that were hits, or 0 if there were
+// none.
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Stats returns the statistics of the cache.
+func (c *EvaluationCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      c.order.Len(),
+	}
+}
+
+// cacheFileVersion is written at the start of the files written by Save.
+const cacheFileVersion = 1
+
+// Save writes the entries of the cache to w, so that they can be restored
+// with Load, e.g. by a later run of the same experiment.
+func (c *EvaluationCache) Save(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Least recently used first, so that Load restores the order
+	entries := make([]cacheEntry, 0, c.order.Len())
+	for e := c.order.Back(); e != nil; e = e.Prev() {
+		entries = append(entries, *e.Value.(*cacheEntry))
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(cacheFileVersion)); err != nil {
+		return err
+	}
+
+	return gob.NewEncoder(w).Encode(entries)
+}
+
+// Load adds the entries written by Save to the cache. If the cache is too
+// small to hold all of them, the least recently used ones are dropped.
+func (c *EvaluationCache) Load(r io.Reader) error {
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+
+	if version != cacheFileVersion {
+		return fmt.Errorf("unknown evaluation cache version %v", version)
+	}
+
+	var entries []cacheEntry
+	if err := gob.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range entries {
+		c.put(e.Key, e.Errors)
+	}
+
+	return nil
+}
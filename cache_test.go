@@ -0,0 +1,169 @@
+package gopush_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+func TestEvaluationCacheEviction(t *testing.T) {
+	cache := gopush.NewEvaluationCache(2)
+
+	k1 := gopush.EvaluationKey{Program: 1}
+	k2 := gopush.EvaluationKey{Program: 2}
+	k3 := gopush.EvaluationKey{Program: 3}
+
+	cache.Put(k1, []float64{1})
+	cache.Put(k2, []float64{2})
+
+	// Using k1 makes k2 the least recently used entry
+	if errors, ok := cache.Get(k1); !ok || errors[0] != 1 {
+		t.Errorf("expected k1 to be cached, got %v %v", errors, ok)
+	}
+
+	cache.Put(k3, []float64{3})
+
+	if _, ok := cache.Get(k2); ok {
+		t.Error("expected k2 to be evicted")
+	}
+
+	if _, ok := cache.Get(k1); !ok {
+		t.Error("expected k1 to be cached")
+	}
+
+	expected := gopush.CacheStats{Hits: 2, Misses: 1, Evictions: 1, Size: 2}
+	if stats := cache.Stats(); stats != expected {
+		t.Errorf("expected stats %+v, got %+v", expected, stats)
+	}
+
+	if rate := cache.Stats().HitRate(); rate != 2.0/3 {
+		t.Errorf("expected a hit rate of 2/3, got %v", rate)
+	}
+}
+
+func TestEvaluationCacheSaveLoad(t *testing.T) {
+	cache := gopush.NewEvaluationCache(10)
+	for p := uint64(1); p <= 3; p++ {
+		cache.Put(gopush.EvaluationKey{Program: p, Cases: 7}, []float64{float64(p), math.Inf(1), math.NaN()})
+	}
+
+	var buf bytes.Buffer
+	if err := cache.Save(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The smaller cache keeps the most recently used entries
+	loaded := gopush.NewEvaluationCache(2)
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := loaded.Get(gopush.EvaluationKey{Program: 1, Cases: 7}); ok {
+		t.Error("expected the least recently used entry to be dropped")
+	}
+
+	errors, ok := loaded.Get(gopush.EvaluationKey{Program: 3, Cases: 7})
+	if !ok || errors[0] != 3 || !math.IsInf(errors[1], 1) || !math.IsNaN(errors[2]) {
+		t.Errorf("expected the errors to be restored, got %v %v", errors, ok)
+	}
+
+	if err := loaded.Load(bytes.NewReader([]byte("nonsense"))); err == nil {
+		t.Error("expected an error while loading an invalid cache")
+	}
+}
+
+// countingCase counts how often programs are run on it
+type countingCase struct {
+	doublingCase
+	runs *int64
+}
+
+func (c countingCase) Setup(i *gopush.Interpreter) {
+	atomic.AddInt64(c.runs, 1)
+	c.doublingCase.Setup(i)
+}
+
+func (c countingCase) String() string {
+	return fmt.Sprintf("counting %d", c.doublingCase)
+}
+
+func TestEvaluatorCache(t *testing.T) {
+	var runs int64
+	cases := []gopush.FitnessCase{countingCase{1, &runs}, countingCase{2, &runs}}
+
+	programs := []gopush.Code{
+		mustParse(t, "INTEGER.DUP INTEGER.+"),
+		mustParse(t, "INTEGER.DUP"),
+		mustParse(t, "INTEGER.DUP INTEGER.+"),
+	}
+
+	options := gopush.DefaultOptions
+	options.RandomSeed = 1138
+
+	uncached := gopush.Evaluator{Options: options, Workers: 2}
+	expected, err := uncached.Evaluate(context.Background(), programs, cases)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cache := gopush.NewEvaluationCache(100)
+	evaluator := gopush.Evaluator{Options: options, Workers: 2, Cache: cache}
+
+	runs = 0
+	for n := 0; n < 2; n++ {
+		errors, err := evaluator.Evaluate(context.Background(), programs, cases)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(errors, expected) {
+			t.Errorf("expected errors %v, got %v", expected, errors)
+		}
+	}
+
+	// Only the two distinct programs are run, and only the first time
+	if runs != 4 {
+		t.Errorf("expected 4 runs, got %v", runs)
+	}
+
+	expectedStats := gopush.CacheStats{Hits: 4, Misses: 2, Size: 2}
+	if stats := cache.Stats(); stats != expectedStats {
+		t.Errorf("expected stats %+v, got %+v", expectedStats, stats)
+	}
+
+	// Other options and other cases do not share the entries
+	options.RandomSeed = 1139
+	evaluator.Options = options
+	evaluator.Evaluate(context.Background(), programs, cases)
+	evaluator.Evaluate(context.Background(), programs, cases[:1])
+
+	if runs != 4+4+2 {
+		t.Errorf("expected 10 runs, got %v", runs)
+	}
+}
+
+func TestEvaluationKey(t *testing.T) {
+	options := gopush.DefaultOptions
+	program := mustParse(t, "1 ( 2 )")
+
+	key := gopush.NewEvaluationKey(program, doublingCases, options)
+
+	if key != gopush.NewEvaluationKey(mustParse(t, "1 ( 2 )"), doublingCases, options) {
+		t.Error("expected equal evaluations to have equal keys")
+	}
+
+	if key == gopush.NewEvaluationKey(program, doublingCases[1:], options) {
+		t.Error("expected different cases to have different keys")
+	}
+
+	options.EvalPushLimit++
+	if key == gopush.NewEvaluationKey(program, doublingCases, options) {
+		t.Error("expected different options to have different keys")
+	}
+}
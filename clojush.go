@@ -0,0 +1,352 @@
+package gopush
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ClojushInstructions maps the names of the gopush instructions to the names
+// of the corresponding Clojush instructions, e.g. INTEGER.+ to integer_add.
+// The keys are upper case. Instructions that are missing from the table, such
+// as those of custom stacks, are translated by the naming convention: the
+// type and the operation are joined by an underscore and the symbolic
+// operations are spelled out, so STRING.= becomes string_eq. Entries can be
+// added or changed to adapt the translation.
+var ClojushInstructions = clojushInstructions()
+
+// clojushTypes are the types whose instructions ParseClojush translates by
+// the naming convention. Besides the builtin types, they include the common
+// Clojush types, which can be provided as custom stacks.
+var clojushTypes = []string{
+	"boolean", "char", "code", "exec", "float", "integer", "name", "string",
+	"vector_boolean", "vector_float", "vector_integer", "vector_string",
+}
+
+// clojushOperations spells out the symbolic operations in Clojush style.
+var clojushOperations = map[string]string{
+	"+": "add",
+	"-": "sub",
+	"*": "mult",
+	"/": "div",
+	"%": "mod",
+	"<": "lt",
+	">": "gt",
+	"=": "eq",
+}
+
+func clojushInstructions() map[string]string {
+	table := make(map[string]string)
+
+	for stack, names := range BuiltinInstructions() {
+		for _, name := range names {
+			table[strings.ToUpper(stack+"."+name)] = clojushName(stack, name)
+		}
+	}
+
+	// Clojush names the Lisp list operations after Clojure
+	table["CODE.CAR"] = "code_first"
+	table["CODE.CDR"] = "code_rest"
+
+	return table
+}
+
+// clojushName translates an instruction by the naming convention.
+func clojushName(stack, operation string) string {
+	if op, ok := clojushOperations[operation]; ok {
+		operation = op
+	}
+
+	return strings.ToLower(stack + "_" + operation)
+}
+
+// FormatClojush writes c in the program syntax of Clojush: instructions are
+// translated using ClojushInstructions and the boolean literals are written
+// in lower case. Names and other literals, such as strings imported with
+// ParseClojush, are written as they are.
+func FormatClojush(c Code) string {
+	if c.Literal != "" {
+		return clojushLiteral(c.Literal)
+	}
+
+	items := make([]string, len(c.List))
+	for n, sub := range c.List {
+		items[n] = FormatClojush(sub)
+	}
+
+	return "(" + strings.Join(items, " ") + ")"
+}
+
+func clojushLiteral(literal string) string {
+	switch strings.ToUpper(literal) {
+	case "TRUE":
+		return "true"
+	case "FALSE":
+		return "false"
+	}
+
+	if name, ok := ClojushInstructions[strings.ToUpper(literal)]; ok {
+		return name
+	}
+
+	// Instructions of custom stacks are translated by the naming
+	// convention, but string and vector literals are kept
+	if dot := strings.Index(literal, "."); dot > 0 && isCaseInsensitive(literal) && !strings.ContainsAny(literal[:1], "\"\\[") {
+		return clojushName(literal[:dot], strings.ToLower(literal[dot+1:]))
+	}
+
+	return literal
+}
+
+// ParseClojush parses a program in the syntax of Clojush, e.g.
+// (1 2 integer_add (exec_do*range ("a" string_length))). Instructions are
+// translated into gopush instructions using ClojushInstructions, or by the
+// naming convention if the type is a builtin type or one of the common Clojush
+// types string, char and vector_*. Strings and characters are kept as
+// literals, so that they can be read by custom stacks (see
+// Stack.ParseLiteral), and vectors are kept as single literals like [1 2 3].
+// Comments are skipped.
+//
+// If the program consists of a single list, as Clojush programs usually do,
+// that list is returned. Otherwise the items are returned as a list, like
+// ParseCode does. Problems are reported as a *ParseError.
+func ParseClojush(program string) (Code, error) {
+	p := &clojushParser{
+		src:          []rune(program),
+		pos:          Position{Line: 1, Column: 1},
+		instructions: make(map[string]string),
+	}
+
+	for instr, name := range ClojushInstructions {
+		p.instructions[name] = instr
+	}
+
+	var items []Code
+	for {
+		p.skipSpace()
+		if p.done() {
+			break
+		}
+
+		if item, ok := p.parseItem(); ok {
+			items = append(items, item)
+		}
+	}
+
+	if len(p.problems) > 0 {
+		sortProblems(p.problems)
+		return Code{}, &ParseError{p.problems}
+	}
+
+	if len(items) == 1 && items[0].Literal == "" {
+		return items[0], nil
+	}
+
+	return newList(items), nil
+}
+
+// clojushParser holds the state of ParseClojush.
+type clojushParser struct {
+	src      []rune
+	n        int
+	pos      Position
+	problems []ParseProblem
+
+	// instructions maps Clojush names to gopush instructions
+	instructions map[string]string
+}
+
+func (p *clojushParser) done() bool {
+	return p.n >= len(p.src)
+}
+
+func (p *clojushParser) peek() rune {
+	return p.src[p.n]
+}
+
+func (p *clojushParser) next() rune {
+	r := p.src[p.n]
+	p.n++
+
+	if r == '\n' {
+		p.pos.Line++
+		p.pos.Column = 1
+	} else {
+		p.pos.Column++
+	}
+
+	return r
+}
+
+func (p *clojushParser) problem(pos Position, format string, args ...interface{}) {
+	p.problems = append(p.problems, ParseProblem{pos, fmt.Sprintf(format, args...)})
+}
+
+// skipSpace skips whitespace, commas and comments.
+func (p *clojushParser) skipSpace() {
+	for !p.done() {
+		switch r := p.peek(); {
+		case r == ';':
+			for !p.done() && p.peek() != '\n' {
+				p.next()
+			}
+		case r == ',' || unicode.IsSpace(r):
+			p.next()
+		default:
+			return
+		}
+	}
+}
+
+// parseItem parses the next item. It returns false if there is no item,
+// e.g. because of an unmatched closing parenthesis.
+func (p *clojushParser) parseItem() (Code, bool) {
+	start := p.pos
+
+	switch p.peek() {
+	case '(':
+		p.next()
+		return newList(p.parseSeq(start, ')')), true
+
+	case '[':
+		p.next()
+		elements := p.parseSeq(start, ']')
+
+		literals := make([]string, len(elements))
+		for n, e := range elements {
+			literals[n] = FormatClojush(e)
+		}
+		return Code{Length: 1, Literal: "[" + strings.Join(literals, " ") + "]"}, true
+
+	case ')', ']':
+		p.problem(start, "%c has no matching opening bracket", p.next())
+		return Code{}, false
+
+	case '{', '}':
+		p.next()
+		p.problem(start, "maps and sets are not supported")
+		return Code{}, false
+
+	case '"':
+		return Code{Length: 1, Literal: p.parseString(start)}, true
+	}
+
+	token := p.parseToken()
+	return Code{Length: 1, Literal: p.literal(token)}, true
+}
+
+// parseSeq parses items up to the closing bracket.
+func (p *clojushParser) parseSeq(start Position, closing rune) []Code {
+	var items []Code
+
+	opening := '('
+	if closing == ']' {
+		opening = '['
+	}
+
+	for {
+		p.skipSpace()
+		if p.done() {
+			p.problem(start, "%c is not closed", opening)
+			return items
+		}
+
+		if p.peek() == closing {
+			p.next()
+			return items
+		}
+
+		if r := p.peek(); r == ')' || r == ']' {
+			p.problem(p.pos, "%c does not match the bracket at %v", r, start)
+			p.next()
+			continue
+		}
+
+		if item, ok := p.parseItem(); ok {
+			items = append(items, item)
+		}
+	}
+}
+
+// parseString parses a string literal, including the quotes and escapes.
+func (p *clojushParser) parseString(start Position) string {
+	begin := p.n
+	p.next()
+
+	for !p.done() {
+		switch p.next() {
+		case '\\':
+			if !p.done() {
+				p.next()
+			}
+		case '"':
+			return string(p.src[begin:p.n])
+		}
+	}
+
+	p.problem(start, "string is not closed")
+	return string(p.src[begin:p.n])
+}
+
+// parseToken parses a symbol, number or character literal.
+func (p *clojushParser) parseToken() string {
+	begin := p.n
+
+	// The character after a backslash belongs to the character literal,
+	// even if it is a delimiter as in \(
+	if p.peek() == '\\' {
+		p.next()
+		if !p.done() {
+			p.next()
+		}
+	}
+
+	for !p.done() {
+		r := p.peek()
+		if unicode.IsSpace(r) || strings.ContainsRune(`,;()[]{}"`, r) {
+			break
+		}
+		p.next()
+	}
+
+	return string(p.src[begin:p.n])
+}
+
+// literal translates a Clojush symbol or literal into a gopush literal.
+func (p *clojushParser) literal(token string) string {
+	switch token {
+	case "true":
+		return "TRUE"
+	case "false":
+		return "FALSE"
+	}
+
+	lower := strings.ToLower(token)
+	if instr, ok := p.instructions[lower]; ok {
+		return instr
+	}
+
+	// Instructions of other known types, e.g. string_length, are
+	// translated by the naming convention. The longest matching type
+	// wins, so that vector_integer_conj is not taken for an instruction
+	// of a vector type.
+	stack := ""
+	for _, t := range clojushTypes {
+		if strings.HasPrefix(lower, t+"_") && len(t) > len(stack) {
+			stack = t
+		}
+	}
+
+	if stack == "" {
+		return token
+	}
+
+	operation := lower[len(stack)+1:]
+	for op, name := range clojushOperations {
+		if name == operation {
+			operation = op
+		}
+	}
+
+	return strings.ToUpper(stack + "." + operation)
+}
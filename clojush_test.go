@@ -0,0 +1,151 @@
+package gopush_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+var clojushTests = []struct {
+	clojush string
+	gopush  string
+}{
+	{"(1 2 integer_add)", "1 2 INTEGER.+"},
+	{"(integer_sub integer_mult integer_div integer_mod integer_lt integer_gt integer_eq)", "INTEGER.- INTEGER.* INTEGER./ INTEGER.% INTEGER.< INTEGER.> INTEGER.="},
+	{"(0 3 exec_do*range (integer_dup float_frominteger))", "0 3 EXEC.DO*RANGE ( INTEGER.DUP FLOAT.FROMINTEGER )"},
+	{"(true false boolean_and 1.5 -2)", "TRUE FALSE BOOLEAN.AND 1.5 -2"},
+	{"(code_quote (a b) code_first code_rest)", "CODE.QUOTE ( a b ) CODE.CAR CODE.CDR"},
+	{"(string_length vector_integer_conj char_isletter)", "STRING.LENGTH VECTOR_INTEGER.CONJ CHAR.ISLETTER"},
+	{"(in1 print_newline)", "in1 print_newline"},
+	{"()", ""},
+	{"(())", "( )"},
+}
+
+// Tests the translation of Clojush programs to gopush and back
+func TestClojush(t *testing.T) {
+	for _, ct := range clojushTests {
+		c, err := gopush.ParseClojush(ct.clojush)
+		if err != nil {
+			t.Errorf("unexpected error while parsing %q: %v", ct.clojush, err)
+			continue
+		}
+
+		expected, err := gopush.ParseCode(ct.gopush)
+		if err != nil {
+			t.Fatalf("unexpected error while parsing %q: %v", ct.gopush, err)
+		}
+
+		if !reflect.DeepEqual(c, expected) {
+			t.Errorf("expected %q to be translated into %v, got %v", ct.clojush, expected, c)
+		}
+
+		if s := gopush.FormatClojush(expected); s != ct.clojush {
+			t.Errorf("expected %v to be translated into %q, got %q", expected, ct.clojush, s)
+		}
+	}
+}
+
+// Tests that strings, characters and vectors are kept as literals
+func TestClojushLiterals(t *testing.T) {
+	c, err := gopush.ParseClojush(`("a (b)" "say \"hi\"", \( [1 2 [true]] ; comment
+	string_concat)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	literals := []string{`"a (b)"`, `"say \"hi\""`, `\(`, "[1 2 [true]]", "STRING.CONCAT"}
+	if len(c.List) != len(literals) {
+		t.Fatalf("expected %v items, got %v", len(literals), c)
+	}
+
+	for n, l := range literals {
+		if c.List[n].Literal != l {
+			t.Errorf("expected item %v to be %v, got %v", n, l, c.List[n])
+		}
+	}
+
+	if s := gopush.FormatClojush(c); s != `("a (b)" "say \"hi\"" \( [1 2 [true]] string_concat)` {
+		t.Errorf("unexpected translation %q", s)
+	}
+}
+
+var clojushErrorTests = []struct {
+	program  string
+	problems []gopush.ParseProblem
+}{
+	{"(1 2", []gopush.ParseProblem{{gopush.Position{1, 1}, "( is not closed"}}},
+	{"(1 2))", []gopush.ParseProblem{{gopush.Position{1, 6}, ") has no matching opening bracket"}}},
+	{"(1\n [2)", []gopush.ParseProblem{
+		{gopush.Position{1, 1}, "( is not closed"},
+		{gopush.Position{2, 2}, "[ is not closed"},
+		{gopush.Position{2, 4}, ") does not match the bracket at 2:2"},
+	}},
+	{`("abc)`, []gopush.ParseProblem{
+		{gopush.Position{1, 1}, "( is not closed"},
+		{gopush.Position{1, 2}, "string is not closed"},
+	}},
+	{"(#{1})", []gopush.ParseProblem{
+		{gopush.Position{1, 3}, "maps and sets are not supported"},
+		{gopush.Position{1, 5}, "maps and sets are not supported"},
+	}},
+}
+
+// Tests that ParseClojush reports malformed programs
+func TestClojushErrors(t *testing.T) {
+	for _, cet := range clojushErrorTests {
+		_, err := gopush.ParseClojush(cet.program)
+
+		perr, ok := err.(*gopush.ParseError)
+		if !ok {
+			t.Errorf("expected a *ParseError while parsing %q, got %v", cet.program, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(perr.Problems, cet.problems) {
+			t.Errorf("expected parsing %q to report %v, got %v", cet.program, cet.problems, perr.Problems)
+		}
+	}
+}
+
+// Tests that the programs of the test suite survive the translation to
+// Clojush and back, and that translated programs run
+func TestClojushRoundTrip(t *testing.T) {
+	for _, suite := range findTestSuites("tests", t) {
+		file := filepath.Join(suite, "2-program.push")
+		program, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("error while reading %q", file)
+		}
+
+		c, err := gopush.ParseCode(string(program))
+		if err != nil {
+			t.Fatalf("unexpected error while parsing %q: %v", file, err)
+		}
+
+		c2, err := gopush.ParseClojush(gopush.FormatClojush(c))
+		if err != nil {
+			t.Errorf("unexpected error while parsing translated %q: %v", file, err)
+		}
+
+		if !reflect.DeepEqual(c, c2) {
+			t.Errorf("expected %q to survive the translation, got %v", file, c2)
+		}
+	}
+
+	c, err := gopush.ParseClojush("(3 (integer_dup integer_mult))")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	interpreter := gopush.NewInterpreter(gopush.DefaultOptions)
+	if err := interpreter.RunCode(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if interpreter.Stacks["integer"].Peek().(int64) != 9 {
+		t.Errorf("expected integer stack to contain 9, got %v", interpreter.Stacks["integer"].Stack)
+	}
+}
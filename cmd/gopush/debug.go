@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataWraith/gopush"
+)
+
+const debugHelp = `The following commands are available:
+
+  break instruction NAME     stop before NAME is executed
+  break step N               stop after N items have been executed
+  break depth STACK OP N     stop when the depth of STACK compares to N
+                             (OP is one of < <= = != >= >)
+  break define NAME          stop after NAME has been (re)defined
+  breakpoints                list all breakpoints
+  delete N                   delete breakpoint N
+  step [N]                   execute the next N items (default 1)
+  continue                   run until a breakpoint is hit or the program ends
+  next                       show the item that will be executed next
+  stacks                     show all stacks
+  defs                       show all definitions
+  push STACK VALUE           push VALUE onto STACK
+  pop STACK                  pop the top item off STACK
+  define NAME CODE           bind NAME to CODE
+  help                       show this help
+  quit                       leave the debugger
+`
+
+// debugSession holds the state of an interactive debugging session.
+type debugSession struct {
+	debugger *gopush.Debugger
+	out      io.Writer
+}
+
+func runDebugger(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var inputs inputList
+
+	fs := flag.NewFlagSet("gopush debug", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	optionsFile := fs.String("options", "", "read the interpreter configuration from `file`")
+	seed := fs.Int64("seed", 0, "seed for the random number generator")
+	limit := fs.Int("limit", 0, "override the EvalPushLimit")
+	fs.Var(&inputs, "input", "run `code` before the program, e.g. to push inputs (repeatable)")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: gopush debug [flags] program.push")
+		return 2
+	}
+
+	// The debugger reads its commands from standard input, so the program
+	// has to come from somewhere else
+	if fs.Arg(0) == "" || fs.Arg(0) == "-" {
+		fmt.Fprintln(stderr, "gopush: debug cannot read the program from standard input, which holds the debugger commands")
+		return 2
+	}
+
+	options, err := loadOptions(*optionsFile, *seed, *limit, false)
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	program, err := readProgram(fs.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	c, err := gopush.ParseCode(program)
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	interpreter := gopush.NewInterpreter(options)
+	interpreter.TraceOutput = stdout
+
+	if err := runInputs(interpreter, inputs); err != nil {
+		fmt.Fprintf(stderr, "gopush: input: %v\n", err)
+		return 2
+	}
+
+	d := &debugSession{debugger: gopush.NewDebugger(interpreter), out: stdout}
+	d.debugger.Load(c)
+
+	fmt.Fprintln(stdout, "gopush debugger, type help for help")
+	d.showNext()
+
+	scanner := bufio.NewScanner(stdin)
+
+	for {
+		fmt.Fprint(stdout, "(debug) ")
+
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			break
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if !d.command(fields) {
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// command executes the given debugger command. It returns false if the
+// debugger should be left.
+func (d *debugSession) command(fields []string) bool {
+	interpreter := d.debugger.Interpreter
+
+	switch fields[0] {
+	case "quit", "q":
+		return false
+
+	case "help":
+		fmt.Fprint(d.out, debugHelp)
+
+	case "break", "b":
+		bp, err := parseBreakpoint(fields[1:])
+		if err != nil {
+			fmt.Fprintf(d.out, "error: %v\n", err)
+			break
+		}
+
+		d.debugger.Breakpoints = append(d.debugger.Breakpoints, bp)
+		fmt.Fprintf(d.out, "breakpoint %d: %v\n", len(d.debugger.Breakpoints), bp)
+
+	case "breakpoints":
+		for n, bp := range d.debugger.Breakpoints {
+			fmt.Fprintf(d.out, "%d: %v\n", n+1, bp)
+		}
+
+	case "delete":
+		n, err := argInt(fields, 1)
+		if err != nil || n < 1 || n > len(d.debugger.Breakpoints) {
+			fmt.Fprintln(d.out, "usage: delete N")
+			break
+		}
+
+		bps := d.debugger.Breakpoints
+		d.debugger.Breakpoints = append(bps[:n-1], bps[n:]...)
+
+	case "step", "s":
+		n := 1
+		if len(fields) > 1 {
+			var err error
+			if n, err = argInt(fields, 1); err != nil || n < 1 {
+				fmt.Fprintln(d.out, "usage: step [N]")
+				break
+			}
+		}
+
+		for ; n > 0 && !d.debugger.Done(); n-- {
+			bp, err := d.debugger.Step()
+			if !d.report(bp, err) {
+				break
+			}
+		}
+		d.showNext()
+
+	case "continue", "c":
+		d.report(d.debugger.Continue())
+		d.showNext()
+
+	case "next", "n":
+		d.showNext()
+
+	case "stacks":
+		writeStacks(d.out, interpreter, "text", nil)
+
+	case "defs":
+		names := make([]string, 0, len(interpreter.Definitions))
+		for name := range interpreter.Definitions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(d.out, "%s = %v\n", name, interpreter.Definitions[name])
+		}
+
+	case "push":
+		if len(fields) < 3 {
+			fmt.Fprintln(d.out, "usage: push STACK VALUE")
+			break
+		}
+
+		s, ok := interpreter.Stacks[strings.ToLower(fields[1])]
+		if !ok {
+			fmt.Fprintf(d.out, "error: unknown stack %q\n", fields[1])
+			break
+		}
+
+		v, err := gopush.ParseValue(strings.ToLower(fields[1]), strings.Join(fields[2:], " "))
+		if err != nil {
+			fmt.Fprintf(d.out, "error: %v\n", err)
+			break
+		}
+
+		s.Push(v)
+
+	case "pop":
+		if len(fields) != 2 {
+			fmt.Fprintln(d.out, "usage: pop STACK")
+			break
+		}
+
+		s, ok := interpreter.Stacks[strings.ToLower(fields[1])]
+		if !ok {
+			fmt.Fprintf(d.out, "error: unknown stack %q\n", fields[1])
+			break
+		}
+
+		if s.Len() > 0 {
+			fmt.Fprintln(d.out, pushLiteral(s.Pop()))
+		}
+
+	case "define":
+		if len(fields) < 3 {
+			fmt.Fprintln(d.out, "usage: define NAME CODE")
+			break
+		}
+
+		c, err := gopush.ParseCode(strings.Join(fields[2:], " "))
+		if err != nil {
+			fmt.Fprintf(d.out, "error: %v\n", err)
+			break
+		}
+
+		if len(c.List) == 1 {
+			c = c.List[0]
+		}
+
+		d.debugger.Define(fields[1], c)
+
+	default:
+		fmt.Fprintf(d.out, "unknown command %s, type help for help\n", fields[0])
+	}
+
+	return true
+}
+
+// report prints why execution stopped. It returns false if execution should
+// not continue.
+func (d *debugSession) report(bp gopush.Breakpoint, err error) bool {
+	switch {
+	case err != nil:
+		fmt.Fprintf(d.out, "error after step %d: %v\n", d.debugger.Steps(), err)
+		return false
+
+	case bp != nil:
+		fmt.Fprintf(d.out, "stopped at %v after step %d\n", bp, d.debugger.Steps())
+		return false
+
+	case d.debugger.Done():
+		fmt.Fprintf(d.out, "program finished after %d steps\n", d.debugger.Steps())
+		return false
+	}
+
+	return true
+}
+
+func (d *debugSession) showNext() {
+	if !d.debugger.Done() {
+		fmt.Fprintf(d.out, "next: %v\n", d.debugger.Next())
+	}
+}
+
+func parseBreakpoint(args []string) (gopush.Breakpoint, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing breakpoint type")
+	}
+
+	switch args[0] {
+	case "instruction":
+		if len(args) == 2 {
+			return gopush.InstructionBreakpoint(args[1]), nil
+		}
+
+	case "step":
+		if n, err := argInt(args, 1); err == nil && len(args) == 2 {
+			return gopush.StepBreakpoint(n), nil
+		}
+
+	case "depth":
+		if n, err := argInt(args, 3); err == nil && len(args) == 4 {
+			return gopush.StackDepthBreakpoint(args[1], args[2], int64(n))
+		}
+
+	case "define":
+		if len(args) == 2 {
+			return gopush.DefinitionBreakpoint(args[1]), nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown breakpoint type %q", args[0])
+	}
+
+	return nil, fmt.Errorf("invalid arguments for %s breakpoint", args[0])
+}
+
+// argInt parses the argument at the given index as integer
+func argInt(args []string, idx int) (int, error) {
+	if idx >= len(args) {
+		return 0, fmt.Errorf("missing argument")
+	}
+
+	return strconv.Atoi(args[idx])
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/DataWraith/gopush"
+)
+
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gopush fmt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	options := gopush.DefaultFormatOptions
+
+	write := fs.Bool("w", false, "write the result to the file instead of standard output")
+	list := fs.Bool("l", false, "list the files whose formatting differs")
+	fs.IntVar(&options.Indent, "indent", options.Indent, "indent nested lists by `n` spaces")
+	fs.IntVar(&options.Width, "width", options.Width, "maximum line width (0 for no limit)")
+	letterCase := fs.String("case", "upper", "case of instructions: upper, lower or preserve")
+	from := fs.String("from", "push", "syntax of the input: push or clojush")
+	to := fs.String("to", "push", "syntax of the output: push or clojush")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	switch *letterCase {
+	case "upper":
+		options.Case = gopush.UpperCase
+	case "lower":
+		options.Case = gopush.LowerCase
+	case "preserve":
+		options.Case = gopush.PreserveCase
+	default:
+		fmt.Fprintf(stderr, "gopush: unknown case %q\n", *letterCase)
+		return 2
+	}
+
+	for _, syntax := range []string{*from, *to} {
+		if syntax != "push" && syntax != "clojush" {
+			fmt.Fprintf(stderr, "gopush: unknown syntax %q\n", syntax)
+			return 2
+		}
+	}
+
+	f := formatter{options: options, clojushIn: *from == "clojush", clojushOut: *to == "clojush"}
+
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "gopush: cannot use -w with standard input")
+			return 2
+		}
+
+		if err := f.formatFile("", stdin, stdout, false, *list); err != nil {
+			fmt.Fprintf(stderr, "gopush: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	status := 0
+	for _, filename := range fs.Args() {
+		if err := f.formatFile(filename, nil, stdout, *write, *list); err != nil {
+			fmt.Fprintf(stderr, "gopush: %s: %v\n", filename, err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+// formatter formats programs, translating them from or to the syntax of
+// Clojush if requested.
+type formatter struct {
+	options    gopush.FormatOptions
+	clojushIn  bool
+	clojushOut bool
+}
+
+// formatFile formats the program in the given file, or the program read from
+// stdin if filename is empty. The result is written back to the file, or to
+// out, and the name of the file is written to out if list is set and the
+// formatting differs.
+func (f formatter) formatFile(filename string, stdin io.Reader, out io.Writer, write, list bool) error {
+	var src []byte
+	var err error
+
+	if filename == "" {
+		src, err = ioutil.ReadAll(stdin)
+	} else {
+		src, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := f.format(string(src))
+	if err != nil {
+		return err
+	}
+
+	if list {
+		if !bytes.Equal(src, res) {
+			if filename == "" {
+				filename = "<stdin>"
+			}
+			fmt.Fprintln(out, filename)
+		}
+		return nil
+	}
+
+	if write {
+		if bytes.Equal(src, res) {
+			return nil
+		}
+
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filename, res, info.Mode())
+	}
+
+	_, err = out.Write(res)
+	return err
+}
+
+// format parses and formats the program.
+func (f formatter) format(src string) ([]byte, error) {
+	var c gopush.Code
+	var err error
+
+	if f.clojushIn {
+		c, err = gopush.ParseClojush(src)
+	} else {
+		if hasComments(src) {
+			return nil, errors.New("cannot format a program with comments, they would be lost")
+		}
+		c, err = gopush.ParseCode(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if f.clojushOut {
+		return []byte(gopush.FormatClojush(c) + "\n"), nil
+	}
+
+	return []byte(c.FormatProgram(f.options)), nil
+}
+
+// hasComments returns whether the program contains comments. Like the
+// parser, it treats parentheses as delimiters, so a comment can start right
+// after one.
+func hasComments(program string) bool {
+	program = strings.NewReplacer("(", " ", ")", " ").Replace(program)
+
+	for _, token := range strings.Fields(program) {
+		if strings.HasPrefix(token, "#") || strings.HasPrefix(token, ";") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+// Command gopush runs programs written in the Push programming language.
+//
+// Usage:
+//
+//	gopush [flags] [program.push]
+//	gopush repl [-options file] [-seed n]
+//	gopush debug [-options file] [-seed n] [-limit n] [-input code] program.push
+//	gopush fmt [-w] [-l] [-indent n] [-width n] [-case upper|lower|preserve]
+//		[-from push|clojush] [-to push|clojush] [program.push ...]
+//	gopush viz [-options file] [-format dot|svg] [-o file] [-title text]
+//		[-clojush] [program.push]
+//
+// The program is read from the given file, or from standard input if no file
+// (or "-") is given. After the program has run, the contents of all stacks are
+// printed. Runtime errors are reported with the line and column of the
+// offending item. The exit status is 1 if the program caused a runtime error
+// and 2 if the program or the options could not be read.
+//
+// Comments start with # or ; and extend to the end of the line.
+//
+// The flags are:
+//
+//	-options file
+//		read the interpreter configuration from file instead of using the
+//		default options; files ending in .json, .yaml or .yml are read as
+//		JSON or YAML
+//	-seed n
+//		seed for the random number generator
+//	-limit n
+//		override the EvalPushLimit
+//	-trace
+//		print the stacks after every executed instruction to standard error
+//	-input code
+//		run code before the program, e.g. to push inputs; can be given
+//		more than once
+//	-format text|json
+//		output format of the final stacks
+//	-clojush
+//		read the program in the syntax of Clojush, e.g. (1 2 integer_add),
+//		see gopush.ParseClojush
+//	-strict
+//		refuse to run if the options allow unknown instructions, instructions
+//		of types that are not allowed, or types without instructions, or if
+//		the program uses instructions of unknown stacks
+//
+// The repl subcommand starts an interactive session that keeps a single
+// interpreter alive. Type :help in the session for the available commands.
+//
+// The debug subcommand loads the program into a debugger that executes it step
+// by step and stops at breakpoints on instructions, step counts, stack depths
+// or definitions. Type help in the debugger for the available commands. The
+// commands are read from standard input, so the program has to be given as a
+// file.
+//
+// The fmt subcommand rewrites programs in a canonical layout: lists that do
+// not fit into the line width are broken up and indented, and instructions
+// are written in upper case. The programs are read from the given files, or
+// from standard input, and written to standard output unless -w is given. -l
+// lists the files whose layout differs instead. Programs with comments are
+// refused, since formatting would lose them. With -from clojush and -to
+// clojush, programs are translated from and to the syntax of Clojush.
+//
+// The viz subcommand renders the program as a tree, either as a Graphviz
+// graph (-format dot, e.g. for dot -Tpdf) or as a standalone SVG image
+// (-format svg). Lists are drawn as dots, instructions as boxes, literals as
+// rounded boxes with a bold border and names as ellipses, each filled with the
+// color of its stack. The -options file decides which atoms are instructions
+// or literals, like when running the program.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "repl":
+			return runREPL(args[1:], stdin, stdout, stderr)
+		case "debug":
+			return runDebugger(args[1:], stdin, stdout, stderr)
+		case "fmt":
+			return runFmt(args[1:], stdin, stdout, stderr)
+		case "viz":
+			return runViz(args[1:], stdin, stdout, stderr)
+		}
+	}
+
+	return runProgram(args, stdin, stdout, stderr)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: gopush [flags] [program.push]")
+	fmt.Fprintln(w, "       gopush repl [-options file] [-seed n]")
+	fmt.Fprintln(w, "       gopush debug [flags] program.push")
+	fmt.Fprintln(w, "       gopush fmt [flags] [program.push ...]")
+	fmt.Fprintln(w, "       gopush viz [flags] [program.push]")
+}
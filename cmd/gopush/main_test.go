@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes content to name in dir and returns the path of the file.
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return path
+}
+
+// runCommand runs gopush with the given arguments and standard input, and
+// returns the exit status and the output.
+func runCommand(args []string, stdin string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+// Tests the exit status for successful runs, runtime errors and unusable
+// programs, options and flags
+func TestExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	integerOnly := writeFile(t, dir, "integer.conf", "type integer\ninstruction integer.+\n")
+	floatInstruction := writeFile(t, dir, "float.conf", "type integer\ninstruction float.+\n")
+
+	for _, tt := range []struct {
+		args   []string
+		stdin  string
+		status int
+	}{
+		{nil, "1 2 INTEGER.+", 0},
+		{[]string{"-"}, "1 2 INTEGER.+", 0},
+		{[]string{"-options", integerOnly}, "1 2 FLOAT.+", 1},
+		{nil, "1 ( 2", 2},
+		{[]string{"-format", "xml"}, "1", 2},
+		{[]string{"-options", filepath.Join(dir, "missing.conf")}, "1", 2},
+		{[]string{"-limit", "-1"}, "1", 2},
+		{[]string{filepath.Join(dir, "missing.push")}, "", 2},
+		{[]string{"-nonsense"}, "", 2},
+		{[]string{"-strict"}, "1 2 INTEGER.+", 0},
+		{[]string{"-strict"}, "1 2 FOO.BAR", 2},
+		{[]string{"-strict", "-options", floatInstruction}, "1", 2},
+		{[]string{"-strict", "-clojush"}, "(1 2 integer_add)", 0},
+		{[]string{"-strict", "-clojush"}, "(\"a\" string_length)", 2},
+		{[]string{"viz", "-format", "png"}, "1", 2},
+		{[]string{"fmt"}, "1 ; comment", 1},
+	} {
+		status, _, stderr := runCommand(tt.args, tt.stdin)
+		if status != tt.status {
+			t.Errorf("expected gopush %v with %q to exit with %v, got %v: %s", tt.args, tt.stdin, tt.status, status, stderr)
+		}
+	}
+}
+
+// Tests the text and JSON output of the final stacks
+func TestOutputFormat(t *testing.T) {
+	status, stdout, _ := runCommand([]string{"-seed", "1"}, "1 2 INTEGER.+ 2.5 TRUE")
+	if status != 0 {
+		t.Fatalf("expected exit status 0, got %v", status)
+	}
+
+	for _, line := range []string{"integer ( 3 )", "float ( 2.5 )", "boolean ( TRUE )"} {
+		if !strings.Contains(stdout, line+"\n") {
+			t.Errorf("expected the output to contain %q, got\n%s", line, stdout)
+		}
+	}
+
+	status, stdout, _ = runCommand([]string{"-format", "json"}, "1 2 INTEGER.+ 2.5 TRUE")
+	if status != 0 {
+		t.Fatalf("expected exit status 0, got %v", status)
+	}
+
+	var out struct {
+		Stacks map[string][]interface{} `json:"stacks"`
+		Error  string                   `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		t.Fatalf("unexpected error while decoding %s: %v", stdout, err)
+	}
+
+	if len(out.Stacks["integer"]) != 1 || out.Stacks["integer"][0] != 3.0 {
+		t.Errorf("expected the integer stack to contain 3, got %v", out.Stacks["integer"])
+	}
+
+	if len(out.Stacks["boolean"]) != 1 || out.Stacks["boolean"][0] != true {
+		t.Errorf("expected the boolean stack to contain true, got %v", out.Stacks["boolean"])
+	}
+
+	if out.Error != "" {
+		t.Errorf("expected no error, got %q", out.Error)
+	}
+}
+
+// Tests that the trace goes to standard error in the order of the stack names,
+// so that it does not mix with the JSON output
+func TestTrace(t *testing.T) {
+	status, stdout, stderr := runCommand([]string{"-trace", "-format", "json"}, "1 2 INTEGER.+")
+	if status != 0 {
+		t.Fatalf("expected exit status 0, got %v: %s", status, stderr)
+	}
+
+	var out interface{}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		t.Errorf("expected only JSON on standard output, got %v:\n%s", err, stdout)
+	}
+
+	step := stderr[:strings.Index(stderr, "\n\n")]
+	if !strings.HasPrefix(step, "Step 0\nboolean:\ncode:\n") || !strings.Contains(step, "\nexec:\n- ( 1 2 INTEGER.+ )\nfloat:\ninteger:\nname:") {
+		t.Errorf("expected the stacks in order of their names, got\n%s", step)
+	}
+
+	status, stdout, _ = runCommand([]string{"repl"}, ":trace on\n1\n")
+	if status != 0 || !strings.Contains(stdout, "Step 1\n") {
+		t.Errorf("expected the REPL to trace to its output, got\n%s", stdout)
+	}
+}
+
+// Tests that inputs are run before the program, in order
+func TestInputs(t *testing.T) {
+	status, stdout, _ := runCommand([]string{"-input", "5", "-input", "7"}, "INTEGER.-")
+	if status != 0 {
+		t.Fatalf("expected exit status 0, got %v", status)
+	}
+
+	if !strings.Contains(stdout, "integer ( -2 )\n") {
+		t.Errorf("expected the integer stack to contain -2, got\n%s", stdout)
+	}
+
+	// Inputs are not pushed onto the CODE stack, but the program is
+	if !strings.Contains(stdout, "code ( ( INTEGER.- ) )\n") {
+		t.Errorf("expected only the program on the code stack, got\n%s", stdout)
+	}
+
+	if status, _, _ := runCommand([]string{"-input", "("}, "1"); status != 2 {
+		t.Errorf("expected an invalid input to exit with 2, got %v", status)
+	}
+}
+
+// Tests that runtime errors are reported with the position of the offending
+// item
+func TestErrorPosition(t *testing.T) {
+	dir := t.TempDir()
+	options := writeFile(t, dir, "integer.conf", "type integer\ninstruction integer.+\n")
+	program := writeFile(t, dir, "program.push", "1 2\n( INTEGER.+ FLOAT.+ )\n")
+
+	status, _, stderr := runCommand([]string{"-options", options, program}, "")
+	if status != 1 {
+		t.Fatalf("expected exit status 1, got %v", status)
+	}
+
+	if !strings.HasPrefix(stderr, "gopush: "+program+":2:13: ") {
+		t.Errorf("expected the error at %s:2:13, got %q", program, stderr)
+	}
+
+	status, _, stderr = runCommand([]string{"-options", options}, "FLOAT.+")
+	if status != 1 {
+		t.Fatalf("expected exit status 1, got %v", status)
+	}
+
+	if !strings.HasPrefix(stderr, "gopush: <stdin>:1:1: ") {
+		t.Errorf("expected the error at <stdin>:1:1, got %q", stderr)
+	}
+
+	// Equal items elsewhere in the program do not hide the position
+	status, _, stderr = runCommand([]string{"-options", options}, "( INTEGER.+ )\n( INTEGER.+ FLOAT.+ )\nFLOAT.+")
+	if status != 1 {
+		t.Fatalf("expected exit status 1, got %v", status)
+	}
+
+	if !strings.HasPrefix(stderr, "gopush: <stdin>:2:13: ") {
+		t.Errorf("expected the error at <stdin>:2:13, got %q", stderr)
+	}
+}
+
+// Tests that every input of the REPL gets its own EvalPushLimit budget
+func TestREPLEvalPushLimit(t *testing.T) {
+	options := writeFile(t, t.TempDir(), "limit.conf", "type integer\nevalpush-limit 10\n")
+
+	status, stdout, stderr := runCommand([]string{"repl", "-options", options}, "1 2 3 4 5\n1 2 3 4 5\n1 2 3 4 5 6 7 8 9 10\n")
+	if status != 0 {
+		t.Fatalf("expected exit status 0, got %v: %s", status, stderr)
+	}
+
+	if n := strings.Count(stdout, "error: the EvalPushLimit was exceeded"); n != 1 {
+		t.Errorf("expected only the last input to exceed the limit, got\n%s", stdout)
+	}
+}
+
+// Tests that the REPL waits for unclosed lists, but not for parentheses in
+// comments
+func TestREPLContinuation(t *testing.T) {
+	status, stdout, stderr := runCommand([]string{"repl"}, "1 ; (\n( 2\n3 ) ; )\n) 4\n")
+	if status != 0 {
+		t.Fatalf("expected exit status 0, got %v: %s", status, stderr)
+	}
+
+	for _, line := range []string{"integer ( 1 )", "integer ( 1 2 3 )", "error: invalid program: 1:1: ) has no matching ("} {
+		if !strings.Contains(stdout, line+"\n") {
+			t.Errorf("expected the output to contain %q, got\n%s", line, stdout)
+		}
+	}
+}
+
+// Tests that the debugger reads its commands from standard input, and thus
+// refuses to read the program from there as well
+func TestDebugStdin(t *testing.T) {
+	for _, arg := range []string{"-", ""} {
+		if status, _, _ := runCommand([]string{"debug", arg}, "1 2 INTEGER.+"); status != 2 {
+			t.Errorf("expected gopush debug %q to exit with 2, got %v", arg, status)
+		}
+	}
+
+	program := writeFile(t, t.TempDir(), "program.push", "1 2 INTEGER.+\n")
+
+	status, stdout, stderr := runCommand([]string{"debug", program}, "continue\nstacks\nquit\n")
+	if status != 0 {
+		t.Fatalf("expected exit status 0, got %v: %s", status, stderr)
+	}
+
+	if !strings.Contains(stdout, "integer ( 3 )\n") {
+		t.Errorf("expected the program to run to the end, got\n%s", stdout)
+	}
+}
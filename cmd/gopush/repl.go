@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataWraith/gopush"
+)
+
+const replHelp = `Enter Push code to run it. Input continues on the next line until all
+parentheses are balanced. The following commands are available:
+
+  :stacks          show all stacks
+  :defs            show all definitions
+  :reset           empty all stacks and remove all definitions
+  :seed N          reseed the random number generator with N
+  :options         show the interpreter options
+  :load FILE       run the program in FILE
+  :trace on|off    print the stacks after every executed instruction
+  :step [CODE]     load CODE for stepping, or execute the next item on the
+                   EXEC stack
+  :help            show this help
+  :quit            leave the REPL
+`
+
+// repl holds the state of an interactive session.
+type repl struct {
+	interpreter *gopush.Interpreter
+	out         io.Writer
+}
+
+func runREPL(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gopush repl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	optionsFile := fs.String("options", "", "read the interpreter configuration from `file`")
+	seed := fs.Int64("seed", 0, "seed for the random number generator")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	options, err := loadOptions(*optionsFile, *seed, 0, false)
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	r := &repl{interpreter: gopush.NewInterpreter(options), out: stdout}
+	r.interpreter.TraceOutput = r.out
+
+	fmt.Fprintln(stdout, "gopush REPL, type :help for help")
+
+	scanner := bufio.NewScanner(stdin)
+	input := ""
+
+	for {
+		if input == "" {
+			fmt.Fprint(stdout, "> ")
+		} else {
+			fmt.Fprint(stdout, "... ")
+		}
+
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			break
+		}
+
+		line := scanner.Text()
+
+		if input == "" && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if !r.command(strings.Fields(strings.TrimSpace(line))) {
+				break
+			}
+			continue
+		}
+
+		input += line + "\n"
+		if isUnclosed(input) {
+			continue
+		}
+
+		r.run(input)
+		input = ""
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// isUnclosed returns true if the only problem of the program s is a list that
+// has not been closed yet, so that the input continues on the next line.
+// Parentheses in comments do not count, just like when the program is parsed.
+func isUnclosed(s string) bool {
+	_, err := gopush.ParseCode(s)
+	perr, ok := err.(*gopush.ParseError)
+	if !ok {
+		return false
+	}
+
+	for _, p := range perr.Problems {
+		if p.Message != "( is not closed" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// run parses and runs the given program and shows the resulting stacks.
+func (r *repl) run(program string) {
+	c, err := gopush.ParseCode(program)
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+		return
+	}
+
+	if len(c.List) == 0 {
+		return
+	}
+
+	// Like RunCode, but every input gets a fresh EvalPushLimit budget
+	r.interpreter.Load(c)
+
+	for err == nil && r.interpreter.Stacks["exec"].Len() > 0 {
+		err = r.interpreter.Step()
+	}
+
+	if r.interpreter.Options.TopLevelPopCode {
+		if s, ok := r.interpreter.Stacks["code"]; ok {
+			s.Pop()
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+
+		if r.interpreter.Stacks["exec"].Len() > 0 {
+			r.interpreter.Stacks["exec"].Flush()
+			fmt.Fprintln(r.out, "the EXEC stack has been flushed")
+		}
+	}
+
+	writeStacks(r.out, r.interpreter, "text", nil)
+}
+
+// command executes the given meta-command. It returns false if the REPL should
+// be left.
+func (r *repl) command(fields []string) bool {
+	switch fields[0] {
+	case ":quit", ":q":
+		return false
+
+	case ":help":
+		fmt.Fprint(r.out, replHelp)
+
+	case ":stacks":
+		writeStacks(r.out, r.interpreter, "text", nil)
+
+	case ":defs":
+		names := make([]string, 0, len(r.interpreter.Definitions))
+		for name := range r.interpreter.Definitions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(r.out, "%s = %v\n", name, r.interpreter.Definitions[name])
+		}
+
+	case ":reset":
+		r.interpreter.Reset(r.interpreter.Options.RandomSeed)
+
+	case ":seed":
+		if len(fields) != 2 {
+			fmt.Fprintln(r.out, "usage: :seed N")
+			break
+		}
+
+		seed, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			fmt.Fprintf(r.out, "error: could not parse %q as integer\n", fields[1])
+			break
+		}
+
+		r.interpreter.Options.RandomSeed = seed
+		r.interpreter.Rand.Seed(seed)
+
+	case ":options":
+		r.interpreter.Options.WriteTo(r.out)
+
+	case ":load":
+		if len(fields) != 2 {
+			fmt.Fprintln(r.out, "usage: :load FILE")
+			break
+		}
+
+		b, err := ioutil.ReadFile(fields[1])
+		if err != nil {
+			fmt.Fprintf(r.out, "error: %v\n", err)
+			break
+		}
+
+		r.run(string(b))
+
+	case ":trace":
+		if len(fields) != 2 || (fields[1] != "on" && fields[1] != "off") {
+			fmt.Fprintln(r.out, "usage: :trace on|off")
+			break
+		}
+
+		r.interpreter.Options.Tracing = fields[1] == "on"
+
+	case ":step":
+		r.step(strings.Join(fields[1:], " "))
+
+	default:
+		fmt.Fprintf(r.out, "unknown command %s, type :help for help\n", fields[0])
+	}
+
+	return true
+}
+
+// step loads the given program for stepping or, if program is empty, executes
+// the next item on the EXEC stack.
+func (r *repl) step(program string) {
+	if program != "" {
+		c, err := gopush.ParseCode(program)
+		if err != nil {
+			fmt.Fprintf(r.out, "error: %v\n", err)
+			return
+		}
+
+		r.interpreter.Load(c)
+		writeStacks(r.out, r.interpreter, "text", nil)
+		return
+	}
+
+	exec := r.interpreter.Stacks["exec"]
+	if exec.Len() == 0 {
+		fmt.Fprintln(r.out, "the EXEC stack is empty")
+		return
+	}
+
+	fmt.Fprintf(r.out, "executing %v\n", exec.Peek())
+
+	if err := r.interpreter.Step(); err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+	}
+
+	writeStacks(r.out, r.interpreter, "text", nil)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataWraith/gopush"
+)
+
+// inputList collects the values of a repeatable flag
+type inputList []string
+
+func (l *inputList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *inputList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func runProgram(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var inputs inputList
+
+	fs := flag.NewFlagSet("gopush", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		usage(stderr)
+		fs.PrintDefaults()
+	}
+
+	optionsFile := fs.String("options", "", "read the interpreter configuration from `file`")
+	seed := fs.Int64("seed", 0, "seed for the random number generator")
+	limit := fs.Int("limit", 0, "override the EvalPushLimit")
+	trace := fs.Bool("trace", false, "print the stacks after every executed instruction to standard error")
+	format := fs.String("format", "text", "output format of the final stacks (text or json)")
+	clojush := fs.Bool("clojush", false, "read the program in the syntax of Clojush")
+	strict := fs.Bool("strict", false, "reject options with unknown or unusable instructions, and programs with instructions of unknown stacks")
+	fs.Var(&inputs, "input", "run `code` before the program, e.g. to push inputs (repeatable)")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "gopush: unknown output format %q\n", *format)
+		return 2
+	}
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	options, err := loadOptions(*optionsFile, *seed, *limit, *trace)
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	program, err := readProgram(fs.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	var c gopush.Code
+	var sm gopush.SourceMap
+
+	if *clojush {
+		c, err = gopush.ParseClojush(program)
+	} else {
+		c, sm, err = gopush.ParseCodePositions(program)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	var interpreter *gopush.Interpreter
+	if *strict {
+		interpreter, err = gopush.NewStrictInterpreter(options)
+	} else {
+		interpreter = gopush.NewInterpreter(options)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	// The trace must not mix with the final stacks, e.g. in JSON
+	interpreter.TraceOutput = stderr
+
+	if *strict && !*clojush {
+		if _, err := interpreter.ParseCodeStrict(program); err != nil {
+			fmt.Fprintf(stderr, "gopush: %v\n", err)
+			return 2
+		}
+	}
+
+	if *strict && *clojush {
+		// Check the translated program. Its positions do not match the
+		// source, so only the problems themselves are reported.
+		if _, err := interpreter.ParseCodeStrict(c.FormatProgram(gopush.FormatOptions{})); err != nil {
+			if perr, ok := err.(*gopush.ParseError); ok {
+				messages := make([]string, len(perr.Problems))
+				for n, p := range perr.Problems {
+					messages[n] = p.Message
+				}
+				err = fmt.Errorf("invalid program: %s", strings.Join(messages, "; "))
+			}
+
+			fmt.Fprintf(stderr, "gopush: %v\n", err)
+			return 2
+		}
+	}
+
+	if err := runInputs(interpreter, inputs); err != nil {
+		fmt.Fprintf(stderr, "gopush: input: %v\n", err)
+		return 2
+	}
+
+	runErr := interpreter.RunCode(c)
+
+	if err := writeStacks(stdout, interpreter, *format, runErr); err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	if runErr != nil {
+		fmt.Fprintf(stderr, "gopush: %s%v\n", errorPosition(fs.Arg(0), c, sm, runErr), runErr)
+		return 1
+	}
+
+	return 0
+}
+
+// loadOptions reads the options file (or uses the default options) and
+// applies the overrides given on the command line.
+func loadOptions(filename string, seed int64, limit int, trace bool) (gopush.Options, error) {
+	options := gopush.DefaultOptions
+
+	if filename != "" {
+		var err error
+		options, err = gopush.ReadOptionsFromFile(filename)
+		if err != nil {
+			return gopush.Options{}, err
+		}
+	}
+
+	if seed != 0 {
+		options.RandomSeed = seed
+	}
+
+	if limit != 0 {
+		if limit < 1 {
+			return gopush.Options{}, fmt.Errorf("the limit must be at least 1, got %v", limit)
+		}
+		options.EvalPushLimit = limit
+	}
+
+	if trace {
+		options.Tracing = true
+	}
+
+	return options, nil
+}
+
+func readProgram(filename string, stdin io.Reader) (string, error) {
+	var b []byte
+	var err error
+
+	if filename == "" || filename == "-" {
+		b, err = ioutil.ReadAll(stdin)
+	} else {
+		b, err = ioutil.ReadFile(filename)
+	}
+
+	return string(b), err
+}
+
+// runInputs runs the given input programs without pushing them onto the CODE
+// stack.
+func runInputs(interpreter *gopush.Interpreter, inputs []string) error {
+	pushCode := interpreter.Options.TopLevelPushCode
+	interpreter.Options.TopLevelPushCode = false
+	defer func() { interpreter.Options.TopLevelPushCode = pushCode }()
+
+	for _, input := range inputs {
+		if err := interpreter.Run(input); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stackNames returns the names of all stacks of the interpreter in sorted
+// order.
+func stackNames(interpreter *gopush.Interpreter) []string {
+	names := make([]string, 0, len(interpreter.Stacks))
+	for name := range interpreter.Stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeStacks writes the stacks of the interpreter in the given format. The
+// text format prints one line per stack, holding the name of the stack
+// followed by its items from bottom to top as a Push list. The json format
+// prints an object mapping stack names to arrays of items, plus the runtime
+// error, if any.
+func writeStacks(w io.Writer, interpreter *gopush.Interpreter, format string, runErr error) error {
+	if format == "json" {
+		out := struct {
+			Stacks map[string][]interface{} `json:"stacks"`
+			Error  string                   `json:"error,omitempty"`
+		}{Stacks: make(map[string][]interface{})}
+
+		for name, s := range interpreter.Stacks {
+			items := make([]interface{}, 0, len(s.Stack))
+			for _, item := range s.Stack {
+				items = append(items, jsonItem(item))
+			}
+			out.Stacks[name] = items
+		}
+
+		if runErr != nil {
+			out.Error = runErr.Error()
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	for _, name := range stackNames(interpreter) {
+		items := []string{name, "("}
+		for _, item := range interpreter.Stacks[name].Stack {
+			items = append(items, pushLiteral(item))
+		}
+		items = append(items, ")")
+
+		if _, err := fmt.Fprintln(w, strings.Join(items, " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pushLiteral formats a stack item the way it would be written in a Push
+// program.
+func pushLiteral(item interface{}) string {
+	switch v := item.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v))
+
+	case gopush.Code:
+		return v.String()
+	}
+
+	return fmt.Sprint(item)
+}
+
+func jsonItem(item interface{}) interface{} {
+	switch v := item.(type) {
+	case int64, bool, string:
+		return v
+
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return pushLiteral(v)
+		}
+		return v
+	}
+
+	return pushLiteral(item)
+}
+
+// errorPosition returns the source position of the item that caused the
+// runtime error, formatted as a prefix for the error message. If the item did
+// not come from the program as written, e.g. because an instruction put it onto
+// the EXEC stack, all points of the program that equal it are listed instead.
+// It returns the empty string if the position is unknown.
+func errorPosition(filename string, c gopush.Code, sm gopush.SourceMap, err error) string {
+	rerr, ok := err.(*gopush.RuntimeError)
+	if !ok || len(sm) == 0 {
+		return ""
+	}
+
+	if filename == "" || filename == "-" {
+		filename = "<stdin>"
+	}
+
+	if rerr.Point >= 0 && rerr.Point < len(sm) {
+		return fmt.Sprintf("%s:%v: ", filename, sm[rerr.Point])
+	}
+
+	positions := sm.Find(c, rerr.Item)
+	switch len(positions) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("%s:%v: ", filename, positions[0])
+	}
+
+	candidates := make([]string, len(positions))
+	for n, p := range positions {
+		candidates[n] = p.String()
+	}
+
+	return fmt.Sprintf("%s: at one of %s: ", filename, strings.Join(candidates, ", "))
+}
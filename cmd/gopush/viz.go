@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/DataWraith/gopush"
+)
+
+func runViz(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gopush viz", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	vizOptions := gopush.DefaultVizOptions
+
+	optionsFile := fs.String("options", "", "read the interpreter configuration from `file`")
+	format := fs.String("format", "dot", "output format: dot or svg")
+	output := fs.String("o", "", "write the result to `file` instead of standard output")
+	clojush := fs.Bool("clojush", false, "read the program in the syntax of Clojush")
+	fs.StringVar(&vizOptions.Title, "title", "", "title written above the tree")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *format != "dot" && *format != "svg" {
+		fmt.Fprintf(stderr, "gopush: unknown output format %q\n", *format)
+		return 2
+	}
+
+	if fs.NArg() > 1 {
+		usage(stderr)
+		return 2
+	}
+
+	options, err := loadOptions(*optionsFile, 0, 0, false)
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	program, err := readProgram(fs.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	var c gopush.Code
+	if *clojush {
+		c, err = gopush.ParseClojush(program)
+	} else {
+		c, err = gopush.ParseCode(program)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 2
+	}
+
+	// The interpreter decides which atoms are literals, instructions or
+	// names, like it would when running the program
+	interpreter := gopush.NewInterpreter(options)
+
+	var buf bytes.Buffer
+	if *format == "svg" {
+		err = interpreter.WriteSVG(&buf, c, vizOptions)
+	} else {
+		err = interpreter.WriteDOT(&buf, c, vizOptions)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 1
+	}
+
+	if *output != "" {
+		err = ioutil.WriteFile(*output, buf.Bytes(), 0644)
+	} else {
+		_, err = stdout.Write(buf.Bytes())
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "gopush: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,371 @@
+// Command pushgp evolves Push programs with genetic programming.
+//
+// Usage:
+//
+//	pushgp [flags] experiment.conf
+//
+// The experiment file uses the format of the interpreter configuration files,
+// extended with parameters that describe the population, the variation
+// operators and the dataset the programs are evaluated on. See
+// gopush.ParseExperiment for the available parameters. A minimal experiment
+// looks like this:
+//
+//	dataset               data.csv
+//	input  x              integer
+//	output y              integer
+//	population-size       500
+//	max-generations       200
+//	selection             lexicase
+//	operator subtree-crossover 0.6
+//	operator uniform-addition  0.2
+//	operator uniform-deletion  0.2
+//
+// After every generation, a summary is printed to standard error.
+//
+// The flags are:
+//
+//	-seed n
+//		seed for the random number generator, overriding the one in the
+//		experiment file
+//	-stats file
+//		write statistics for every generation to file, as JSON lines if
+//		the name ends in .json or .jsonl and as CSV otherwise
+//	-best file
+//		write the best program of the final generation to file
+//	-checkpoint file
+//		save the population to file after every generation
+//	-resume file
+//		continue the run from the population saved in file
+//	-cache-size n
+//		remember the errors of up to n programs, so that programs that
+//		appear again are not evaluated again
+//	-cache file
+//		load the remembered errors from file before the run and save them
+//		afterwards; implies a cache size of 100000 unless -cache-size is
+//		given. The errors depend on the random seed, so the file is only
+//		used if the seed is fixed by -seed, the experiment or the
+//		checkpoint the run resumes from
+//
+// The exit status is 0 if a program reached the error threshold, 1 if the run
+// ended without one and 2 if the experiment could not be set up.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/DataWraith/gopush"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("pushgp", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	seed := fs.Int64("seed", 0, "seed for the random number generator")
+	statsFile := fs.String("stats", "", "write per-generation statistics to `file` (CSV, or JSON lines for .json/.jsonl)")
+	bestFile := fs.String("best", "", "write the best program to `file`")
+	checkpointFile := fs.String("checkpoint", "", "save the population to `file` after every generation")
+	resumeFile := fs.String("resume", "", "continue from the population saved in `file`")
+	cacheSize := fs.Int("cache-size", 0, "remember the errors of up to `n` programs")
+	cacheFile := fs.String("cache", "", "load and save the remembered errors in `file` (needs a fixed seed)")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *cacheSize < 0 {
+		fmt.Fprintf(stderr, "pushgp: the cache size must not be negative, got %v\n", *cacheSize)
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: pushgp [flags] experiment.conf")
+		return 2
+	}
+
+	e, err := gopush.ReadExperimentFromFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "pushgp: %v\n", err)
+		return 2
+	}
+
+	if *seed != 0 {
+		e.Options.RandomSeed = *seed
+	}
+
+	if err := e.Options.Validate(nil); err != nil {
+		fmt.Fprintf(stderr, "pushgp: %v\n", err)
+		return 2
+	}
+
+	dataset, err := e.LoadDataset()
+	if err != nil {
+		fmt.Fprintf(stderr, "pushgp: %v\n", err)
+		return 2
+	}
+
+	var population *gopush.Population
+	if *resumeFile != "" {
+		if population, err = readCheckpoint(&e, *resumeFile); err != nil {
+			fmt.Fprintf(stderr, "pushgp: %v\n", err)
+			return 2
+		}
+	}
+
+	if *cacheFile != "" && *cacheSize == 0 {
+		*cacheSize = defaultCacheSize
+	}
+
+	// The remembered errors are only valid for the seed of the run, and a
+	// random seed is never used again
+	if *cacheFile != "" && e.Options.RandomSeed == 0 {
+		fmt.Fprintln(stderr, "pushgp: the random seed is not fixed, so the cache is not loaded or saved")
+		*cacheFile = ""
+	}
+
+	if *cacheSize > 0 {
+		e.Cache = gopush.NewEvaluationCache(*cacheSize)
+	}
+
+	if *cacheFile != "" {
+		if err := loadCache(e.Cache, *cacheFile); err != nil {
+			fmt.Fprintf(stderr, "pushgp: %v\n", err)
+			return 2
+		}
+	}
+
+	var stats statsWriter = nopStats{}
+	if *statsFile != "" {
+		f, err := openStats(*statsFile, *resumeFile != "")
+		if err != nil {
+			fmt.Fprintf(stderr, "pushgp: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+
+		stats = newStatsWriter(f, *statsFile, *resumeFile != "")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	report := func(p *gopush.Population, s gopush.GenerationStats) error {
+		fmt.Fprintf(stderr, "generation %d: best %g, median %g, mean size %.1f, diversity %.2f",
+			s.Generation, s.BestTotalError, s.MedianTotalError, s.MeanSize, s.Diversity)
+		if e.Cache != nil {
+			fmt.Fprintf(stderr, ", cache hit rate %.2f", e.Cache.Stats().HitRate())
+		}
+		fmt.Fprintln(stderr)
+
+		if err := stats.Write(s); err != nil {
+			return err
+		}
+
+		if *checkpointFile != "" {
+			return writeCheckpoint(&e, p, *checkpointFile)
+		}
+
+		return nil
+	}
+
+	population, err = e.Evolve(ctx, dataset.FitnessCases(), population, report)
+	if err != nil {
+		fmt.Fprintf(stderr, "pushgp: %v\n", err)
+	}
+
+	if e.Cache != nil {
+		cs := e.Cache.Stats()
+		fmt.Fprintf(stderr, "cache: %d hits, %d misses, %d evictions, hit rate %.2f\n", cs.Hits, cs.Misses, cs.Evictions, cs.HitRate())
+
+		if *cacheFile != "" {
+			if err := saveCache(e.Cache, *cacheFile); err != nil {
+				fmt.Fprintf(stderr, "pushgp: %v\n", err)
+			}
+		}
+	}
+
+	if population == nil || population.Errors == nil {
+		return 1
+	}
+
+	best := population.Stats()
+	fmt.Fprintf(stdout, "%v\n", best.BestProgram)
+
+	if *bestFile != "" {
+		if err := ioutil.WriteFile(*bestFile, []byte(best.BestProgram.String()+"\n"), 0644); err != nil {
+			fmt.Fprintf(stderr, "pushgp: %v\n", err)
+			return 1
+		}
+	}
+
+	if err != nil || best.BestTotalError > e.ErrorThreshold {
+		return 1
+	}
+
+	return 0
+}
+
+// defaultCacheSize is the cache size used if only -cache is given.
+const defaultCacheSize = 100000
+
+// loadCache loads the cache from the file, if it exists.
+func loadCache(cache *gopush.EvaluationCache, filename string) error {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return cache.Load(bufio.NewReader(f))
+}
+
+// saveCache saves the cache to a temporary file first, like writeCheckpoint.
+func saveCache(cache *gopush.EvaluationCache, filename string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".pushgp-cache")
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+
+	err = cache.Save(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+func readCheckpoint(e *gopush.Experiment, filename string) (*gopush.Population, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return e.ReadCheckpoint(f)
+}
+
+// writeCheckpoint writes the checkpoint to a temporary file first, so that an
+// interrupted write does not destroy the previous checkpoint.
+func writeCheckpoint(e *gopush.Experiment, p *gopush.Population, filename string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".pushgp-checkpoint")
+	if err != nil {
+		return err
+	}
+
+	if err := e.WriteCheckpoint(tmp, p); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// openStats opens the statistics file. When resuming, new statistics are
+// appended to the existing file.
+func openStats(filename string, resume bool) (*os.File, error) {
+	if resume {
+		return os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	}
+
+	return os.Create(filename)
+}
+
+type statsWriter interface {
+	Write(s gopush.GenerationStats) error
+}
+
+type nopStats struct{}
+
+func (nopStats) Write(s gopush.GenerationStats) error { return nil }
+
+func newStatsWriter(w io.Writer, filename string, resume bool) statsWriter {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json", ".jsonl":
+		return jsonStats{json.NewEncoder(w)}
+	}
+
+	return &csvStats{w: csv.NewWriter(w), header: !resume}
+}
+
+type jsonStats struct {
+	enc *json.Encoder
+}
+
+func (js jsonStats) Write(s gopush.GenerationStats) error {
+	return js.enc.Encode(map[string]interface{}{
+		"generation":         s.Generation,
+		"best-total-error":   s.BestTotalError,
+		"median-total-error": s.MedianTotalError,
+		"mean-total-error":   s.MeanTotalError,
+		"best-size":          s.BestSize,
+		"mean-size":          s.MeanSize,
+		"diversity":          s.Diversity,
+		"best-program":       s.BestProgram.String(),
+	})
+}
+
+type csvStats struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (cs *csvStats) Write(s gopush.GenerationStats) error {
+	if cs.header {
+		cs.w.Write([]string{"generation", "best-total-error", "median-total-error", "mean-total-error", "best-size", "mean-size", "diversity", "best-program"})
+		cs.header = false
+	}
+
+	cs.w.Write([]string{
+		strconv.Itoa(s.Generation),
+		formatFloat(s.BestTotalError),
+		formatFloat(s.MedianTotalError),
+		formatFloat(s.MeanTotalError),
+		strconv.Itoa(s.BestSize),
+		formatFloat(s.MeanSize),
+		formatFloat(s.Diversity),
+		s.BestProgram.String(),
+	})
+	cs.w.Flush()
+
+	return cs.w.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
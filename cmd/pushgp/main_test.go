@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeExperiment writes a small experiment that runs for the given number of
+// generations to dir and returns the path of the experiment file.
+func writeExperiment(t *testing.T, dir string, generations int) string {
+	var data bytes.Buffer
+	data.WriteString("x,y\n")
+	for x := -5; x <= 5; x++ {
+		fmt.Fprintf(&data, "%d,%d\n", x, x*x*x-3*x+11)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "data.csv"), data.Bytes(), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	experiment := fmt.Sprintf(`dataset data.csv
+input x integer
+output y integer
+population-size 20
+max-generations %d
+random-seed 7
+`, generations)
+
+	path := filepath.Join(dir, fmt.Sprintf("experiment-%d.conf", generations))
+	if err := ioutil.WriteFile(path, []byte(experiment), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return path
+}
+
+// runPushGP runs pushgp with the given arguments and returns the exit status
+// and the output.
+func runPushGP(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+// readGenerations returns the generations recorded in a JSON lines statistics
+// file.
+func readGenerations(t *testing.T, filename string) []int {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	var generations []int
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s struct {
+			Generation int `json:"generation"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatalf("unexpected error while decoding %s: %v", scanner.Bytes(), err)
+		}
+		generations = append(generations, s.Generation)
+	}
+
+	return generations
+}
+
+// Tests that a run that is checkpointed and resumed ends like an uninterrupted
+// run, and that the statistics of the resumed run are appended
+func TestCheckpointAndResume(t *testing.T) {
+	dir := t.TempDir()
+	short := writeExperiment(t, dir, 2)
+	long := writeExperiment(t, dir, 4)
+
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+	stats := filepath.Join(dir, "stats.jsonl")
+	best := filepath.Join(dir, "best.push")
+
+	status, _, stderr := runPushGP("-checkpoint", checkpoint, "-stats", stats, short)
+	if status != 1 {
+		t.Fatalf("expected the short run to exit with 1, got %v: %s", status, stderr)
+	}
+
+	if _, err := os.Stat(checkpoint); err != nil {
+		t.Fatalf("expected a checkpoint: %v", err)
+	}
+
+	status, resumed, stderr := runPushGP("-resume", checkpoint, "-stats", stats, "-best", best, long)
+	if status != 1 {
+		t.Fatalf("expected the resumed run to exit with 1, got %v: %s", status, stderr)
+	}
+
+	status, uninterrupted, stderr := runPushGP(long)
+	if status != 1 {
+		t.Fatalf("expected the uninterrupted run to exit with 1, got %v: %s", status, stderr)
+	}
+
+	if resumed == "" || resumed != uninterrupted {
+		t.Errorf("expected the resumed run to find %q, got %q", uninterrupted, resumed)
+	}
+
+	if b, err := ioutil.ReadFile(best); err != nil || string(b) != resumed {
+		t.Errorf("expected %q in the best program file, got %q (%v)", resumed, b, err)
+	}
+
+	generations := readGenerations(t, stats)
+	if fmt.Sprint(generations) != "[0 1 2 3 4]" {
+		t.Errorf("expected statistics for generations 0 to 4, got %v", generations)
+	}
+}
+
+// Tests that the cache file is saved and used again with a fixed seed, but
+// neither loaded nor saved with a random one
+func TestCacheFile(t *testing.T) {
+	dir := t.TempDir()
+	experiment := writeExperiment(t, dir, 1)
+	cache := filepath.Join(dir, "cache.gob")
+
+	status, first, stderr := runPushGP("-cache", cache, experiment)
+	if status != 1 {
+		t.Fatalf("expected exit status 1, got %v: %s", status, stderr)
+	}
+
+	if _, err := os.Stat(cache); err != nil {
+		t.Fatalf("expected a cache file: %v", err)
+	}
+
+	status, second, stderr := runPushGP("-cache", cache, experiment)
+	if status != 1 {
+		t.Fatalf("expected exit status 1, got %v: %s", status, stderr)
+	}
+
+	if second != first {
+		t.Errorf("expected the cached run to find %q, got %q", first, second)
+	}
+
+	if !strings.Contains(stderr, "cache: 40 hits, 0 misses") {
+		t.Errorf("expected every program to be taken from the cache, got\n%s", stderr)
+	}
+
+	random := filepath.Join(dir, "random.conf")
+	b, err := ioutil.ReadFile(experiment)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(random, bytes.Replace(b, []byte("random-seed 7\n"), nil, 1), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	randomCache := filepath.Join(dir, "random.gob")
+	status, _, stderr = runPushGP("-cache", randomCache, random)
+	if status != 1 {
+		t.Fatalf("expected exit status 1, got %v: %s", status, stderr)
+	}
+
+	if !strings.Contains(stderr, "the cache is not loaded or saved") {
+		t.Errorf("expected a warning about the random seed, got\n%s", stderr)
+	}
+
+	if _, err := os.Stat(randomCache); !os.IsNotExist(err) {
+		t.Errorf("expected no cache file for a random seed, got %v", err)
+	}
+}
+
+// Tests that unusable flags and experiments exit with status 2
+func TestSetupErrors(t *testing.T) {
+	dir := t.TempDir()
+	experiment := writeExperiment(t, dir, 1)
+
+	for _, args := range [][]string{
+		{},
+		{"-nonsense", experiment},
+		{filepath.Join(dir, "missing.conf")},
+		{"-resume", filepath.Join(dir, "missing.json"), experiment},
+		{"-cache", filepath.Join(dir, "cache.gob"), "-cache-size", "-1", experiment},
+	} {
+		if status, _, _ := runPushGP(args...); status != 2 {
+			t.Errorf("expected pushgp %v to exit with 2, got %v", args, status)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package gopush
 
 import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
 	"math"
-	"reflect"
+	"sort"
+	"strings"
 )
 
 // Code is the internal list representation of a (partial) Push program.
@@ -24,62 +28,255 @@ func (c Code) String() string {
 	return s + ")"
 }
 
+// Equal returns whether c and c2 are the same literal, or lists of equal
+// items. Unlike reflect.DeepEqual, it does not distinguish between nil and
+// empty lists, and ignores the Length.
+func (c Code) Equal(c2 Code) bool {
+	if c.Literal != "" || c2.Literal != "" {
+		return c.Literal == c2.Literal
+	}
+
+	if len(c.List) != len(c2.List) {
+		return false
+	}
+
+	for n := range c.List {
+		if !c.List[n].Equal(c2.List[n]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Hash returns a 64-bit hash of the structure of c, such that equal code (see
+// Equal) has equal hashes. The hash is stable, i.e. the same across runs and
+// machines, so it can be stored, e.g. to cache fitness values.
+func (c Code) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [binary.MaxVarintLen64 + 1]byte
+
+	var write func(c Code)
+	write = func(c Code) {
+		// Atoms and lists are tagged and prefixed with their size, so
+		// that different structures do not write the same bytes
+		if c.Literal != "" {
+			buf[0] = 'a'
+			n := binary.PutUvarint(buf[1:], uint64(len(c.Literal)))
+			h.Write(buf[:n+1])
+			h.Write([]byte(c.Literal))
+			return
+		}
+
+		buf[0] = 'l'
+		n := binary.PutUvarint(buf[1:], uint64(len(c.List)))
+		h.Write(buf[:n+1])
+
+		for _, sub := range c.List {
+			write(sub)
+		}
+	}
+	write(c)
+
+	return h.Sum64()
+}
+
+// Position is a location in the source of a Push program. Lines and columns
+// start at 1, and columns count characters.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// SourceMap holds the source position of every point of a parsed program,
+// indexed by point number. Points are numbered in depth-first order, starting
+// with 0 for the program itself. The position of a list is the position of
+// its opening parenthesis.
+type SourceMap []Position
+
+// Find returns the positions of the points of program that equal item. It is
+// useful for items of a RuntimeError that have no Point, e.g. because an
+// instruction built them. m must be the SourceMap of program.
+func (m SourceMap) Find(program Code, item Code) []Position {
+	var positions []Position
+
+	program.Walk(func(point int, c Code) bool {
+		if point < len(m) && c.Equal(item) {
+			positions = append(positions, m[point])
+		}
+		return true
+	})
+
+	return positions
+}
+
+// ParseError lists the problems found while parsing a Push program.
+type ParseError struct {
+	Problems []ParseProblem
+}
+
+func (e *ParseError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for n, p := range e.Problems {
+		problems[n] = p.String()
+	}
+
+	return "invalid program: " + strings.Join(problems, "; ")
+}
+
+// ParseProblem is a single problem found while parsing a Push program.
+type ParseProblem struct {
+	Pos     Position
+	Message string
+}
+
+func (p ParseProblem) String() string {
+	return fmt.Sprintf("%v: %s", p.Pos, p.Message)
+}
+
 // ParseCode takes the provided Push program and parses it into the internal
-// list representation (type Code).
+// list representation (type Code). Parentheses delimit lists whether or not
+// they are surrounded by whitespace, so "(1 2 INTEGER.+)" and
+// "( 1 2 INTEGER.+ )" are equivalent. Comments start with # or ; at the
+// beginning of a token and extend to the end of the line. Unbalanced
+// parentheses are reported as a *ParseError.
 func ParseCode(program string) (c Code, err error) {
-	t := ""
-	p := program
+	c, _, err = ParseCodePositions(program)
+	return c, err
+}
 
-	for len(p) > 0 {
-		p = ignoreWhiteSpace(p, true)
-		t, p = getToken(p)
+// ParseCodePositions is like ParseCode, but also returns the source position
+// of every point of the program.
+func ParseCodePositions(program string) (c Code, m SourceMap, err error) {
+	c, m, problems := parseCode(program)
+	if len(problems) > 0 {
+		return Code{}, nil, &ParseError{problems}
+	}
 
-		if t == "" {
-			break
-		}
+	return c, m, nil
+}
 
-		if t == "(" {
-			t, p, err = getToParen(p)
-			if err != nil {
-				return Code{}, err
-			}
+// ParseCodeStrict is like ParseCode, but additionally reports tokens that
+// look like instructions (e.g. FOO.BAR) of stacks the interpreter does not
+// have. Such tokens would otherwise only cause an error when executed.
+func (i *Interpreter) ParseCodeStrict(program string) (Code, error) {
+	c, m, problems := parseCode(program)
+
+	point := 0
+
+	var check func(c Code)
+	check = func(c Code) {
+		pos := m[point]
+		point++
 
-			sublist, err := ParseCode(t)
-			if err != nil {
-				return Code{}, err
+		if c.Literal != "" {
+			if stack := i.instructionStack(c.Literal); stack != "" {
+				if _, ok := i.Stacks[stack]; !ok {
+					problems = append(problems, ParseProblem{pos, fmt.Sprintf("%v refers to unknown stack %v", c.Literal, stack)})
+				}
 			}
+			return
+		}
 
-			c.List = append(c.List, sublist)
-			c.Length += 1 + sublist.Length
-		} else {
-			c.List = append(c.List, Code{Length: 1, Literal: t})
-			c.Length++
+		for _, sub := range c.List {
+			check(sub)
 		}
 	}
+	check(c)
+
+	if len(problems) > 0 {
+		sortProblems(problems)
+		return Code{}, &ParseError{problems}
+	}
 
 	return c, nil
 }
 
+// parseCode parses the program, returning all problems found. Unbalanced
+// parentheses are skipped, so that the remaining program can still be
+// checked.
+func parseCode(program string) (c Code, m SourceMap, problems []ParseProblem) {
+	// lists holds the lists that are currently open, innermost last, and
+	// opened the positions of their opening parentheses
+	lists := []Code{{}}
+	opened := []Position{{Line: 1, Column: 1}}
+
+	m = SourceMap{{Line: 1, Column: 1}}
+
+	for _, t := range lexCode(program) {
+		switch t.text {
+		case "(":
+			lists = append(lists, Code{})
+			opened = append(opened, t.pos)
+			m = append(m, t.pos)
+
+		case ")":
+			if len(lists) == 1 {
+				problems = append(problems, ParseProblem{t.pos, ") has no matching ("})
+				continue
+			}
+
+			sublist := lists[len(lists)-1]
+			lists = lists[:len(lists)-1]
+			opened = opened[:len(opened)-1]
+
+			parent := &lists[len(lists)-1]
+			parent.List = append(parent.List, sublist)
+			parent.Length += 1 + sublist.Length
+
+		default:
+			top := &lists[len(lists)-1]
+			top.List = append(top.List, Code{Length: 1, Literal: t.text})
+			top.Length++
+			m = append(m, t.pos)
+		}
+	}
+
+	// Close the lists that are still open, so that the source map stays
+	// consistent with the code
+	for len(lists) > 1 {
+		problems = append(problems, ParseProblem{opened[len(opened)-1], "( is not closed"})
+
+		sublist := lists[len(lists)-1]
+		lists = lists[:len(lists)-1]
+		opened = opened[:len(opened)-1]
+
+		parent := &lists[len(lists)-1]
+		parent.List = append(parent.List, sublist)
+		parent.Length += 1 + sublist.Length
+	}
+
+	sortProblems(problems)
+
+	return lists[0], m, problems
+}
+
+// sortProblems sorts the problems by their position in the source.
+func sortProblems(problems []ParseProblem) {
+	sort.SliceStable(problems, func(a, b int) bool {
+		pa, pb := problems[a].Pos, problems[b].Pos
+		return pa.Line < pb.Line || pa.Line == pb.Line && pa.Column < pb.Column
+	})
+}
+
 // Container returns the "container" of the given Code c2 in c. That is, it
 // returns the smallest sublist of c which contains c2, or the empty list if
 // none of the sublists in c contain c2.
-func (c Code) Container(c2 Code) (container Code) {
-	container.Length = math.MaxInt32
-
-	if c.Literal != "" {
-		return
-	}
+func (c Code) Container(c2 Code) Code {
+	container := Code{Length: math.MaxInt32}
 
-	for _, sl := range c.List {
-		if reflect.DeepEqual(sl, c2) && c.Length < container.Length {
-			container = c
-		} else {
-			candidate := sl.Container(c2)
-			if candidate.Length < container.Length {
-				container = candidate
+	c.Walk(func(_ int, sub Code) bool {
+		for _, sl := range sub.List {
+			if sl.Equal(c2) && sub.Length < container.Length {
+				container = sub
 			}
 		}
-	}
+		return true
+	})
 
 	if container.Length == math.MaxInt32 {
 		return Code{}
@@ -91,17 +288,9 @@ func (c Code) Container(c2 Code) (container Code) {
 // Contains returns whether the Code c is equal to c2 or contains it in any
 // sublist
 func (c Code) Contains(c2 Code) bool {
-	if reflect.DeepEqual(c, c2) {
-		return true
-	}
-
-	for _, sl := range c.List {
-		if sl.Contains(c2) {
-			return true
-		}
-	}
-
-	return false
+	return !c.Walk(func(_ int, sub Code) bool {
+		return !sub.Equal(c2)
+	})
 }
 
 // UniqueItems returns a map with the count of all unique items in the Code list
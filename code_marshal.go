@@ -0,0 +1,263 @@
+package gopush
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// codeBinaryVersion is the first byte of the binary encoding of Code.
+const codeBinaryVersion = 1
+
+// maxCodeBinaryDepth is the deepest nesting of lists that MarshalBinary and
+// UnmarshalBinary accept. It keeps corrupt input from exhausting the stack.
+const maxCodeBinaryDepth = 10000
+
+// MarshalBinary encodes c in a compact binary format. The literals are
+// stored once, in a table in the order of their first appearance, and the
+// structure refers to them by index:
+//
+//	version    byte (1)
+//	literals   uvarint n, followed by n times: uvarint length, bytes
+//	structure  the points of c in depth-first order, each an uvarint:
+//	           2*index+1 for a literal, 2*n for a list of n items
+//
+// All uvarints are encoded as by encoding/binary. Length is not stored, but
+// recomputed when decoding. Code nested deeper than 10000 lists is an error.
+func (c Code) MarshalBinary() ([]byte, error) {
+	index := make(map[string]int)
+	var literals []string
+
+	var structure []byte
+
+	var encode func(c Code, depth int) error
+	encode = func(c Code, depth int) error {
+		if c.Literal != "" {
+			n, ok := index[c.Literal]
+			if !ok {
+				n = len(literals)
+				index[c.Literal] = n
+				literals = append(literals, c.Literal)
+			}
+
+			structure = appendUvarint(structure, uint64(2*n+1))
+			return nil
+		}
+
+		if depth >= maxCodeBinaryDepth {
+			return fmt.Errorf("code is nested deeper than %v lists", maxCodeBinaryDepth)
+		}
+
+		structure = appendUvarint(structure, uint64(2*len(c.List)))
+		for _, sub := range c.List {
+			if err := encode(sub, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := encode(c, 0); err != nil {
+		return nil, err
+	}
+
+	b := []byte{codeBinaryVersion}
+	b = appendUvarint(b, uint64(len(literals)))
+	for _, l := range literals {
+		b = appendUvarint(b, uint64(len(l)))
+		b = append(b, l...)
+	}
+
+	return append(b, structure...), nil
+}
+
+// appendUvarint appends the uvarint encoding of v to b.
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// UnmarshalBinary decodes code encoded by MarshalBinary. Like MarshalBinary,
+// it rejects code nested deeper than 10000 lists.
+func (c *Code) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != codeBinaryVersion {
+		return errors.New("invalid binary code: unknown version")
+	}
+	data = data[1:]
+
+	uvarint := func() (uint64, error) {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return 0, errors.New("invalid binary code: truncated or overlong number")
+		}
+		data = data[n:]
+		return v, nil
+	}
+
+	count, err := uvarint()
+	if err != nil {
+		return err
+	}
+
+	// Every literal takes at least two bytes, which bounds the size of the
+	// table for corrupt input
+	if count > uint64(len(data)/2) {
+		return errors.New("invalid binary code: too many literals")
+	}
+
+	literals := make([]string, count)
+	for n := range literals {
+		length, err := uvarint()
+		if err != nil {
+			return err
+		}
+
+		if length == 0 || length > uint64(len(data)) {
+			return fmt.Errorf("invalid binary code: literal %v has an invalid length", n)
+		}
+
+		literals[n] = string(data[:length])
+		data = data[length:]
+	}
+
+	var decode func(depth int) (Code, error)
+	decode = func(depth int) (Code, error) {
+		v, err := uvarint()
+		if err != nil {
+			return Code{}, err
+		}
+
+		if v%2 == 1 {
+			if v/2 >= uint64(len(literals)) {
+				return Code{}, errors.New("invalid binary code: unknown literal")
+			}
+			return Code{Length: 1, Literal: literals[v/2]}, nil
+		}
+
+		// Every item takes at least one byte
+		if v/2 > uint64(len(data)) {
+			return Code{}, errors.New("invalid binary code: truncated list")
+		}
+
+		if depth >= maxCodeBinaryDepth {
+			return Code{}, fmt.Errorf("invalid binary code: nested deeper than %v lists", maxCodeBinaryDepth)
+		}
+
+		var items []Code
+		for n := uint64(0); n < v/2; n++ {
+			item, err := decode(depth + 1)
+			if err != nil {
+				return Code{}, err
+			}
+			items = append(items, item)
+		}
+
+		return newList(items), nil
+	}
+
+	decoded, err := decode(0)
+	if err != nil {
+		return err
+	}
+
+	if len(data) > 0 {
+		return errors.New("invalid binary code: trailing data")
+	}
+
+	*c = decoded
+	return nil
+}
+
+// MarshalText encodes c as returned by String.
+func (c Code) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes code encoded by MarshalText. Since String wraps lists
+// in parentheses, a text consisting of a single item decodes into that item.
+// Other texts decode into the list of their items, like ParseCode does.
+func (c *Code) UnmarshalText(text []byte) error {
+	parsed, err := ParseCode(string(text))
+	if err != nil {
+		return err
+	}
+
+	if len(parsed.List) == 1 {
+		parsed = parsed.List[0]
+	}
+
+	*c = parsed
+	return nil
+}
+
+// MarshalJSON encodes c as JSON: literals as strings and lists as arrays, so
+// that ( 1 ( 2 3 ) INTEGER.+ ) becomes ["1",["2","3"],"INTEGER.+"]. Literals
+// that are not valid UTF-8 cannot be represented and are an error.
+func (c Code) MarshalJSON() ([]byte, error) {
+	v, err := c.jsonValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+func (c Code) jsonValue() (interface{}, error) {
+	if c.Literal != "" {
+		if !utf8.ValidString(c.Literal) {
+			return nil, fmt.Errorf("literal %q is not valid UTF-8", c.Literal)
+		}
+		return c.Literal, nil
+	}
+
+	items := make([]interface{}, len(c.List))
+	for n, sub := range c.List {
+		v, err := sub.jsonValue()
+		if err != nil {
+			return nil, err
+		}
+		items[n] = v
+	}
+	return items, nil
+}
+
+// UnmarshalJSON decodes code encoded by MarshalJSON.
+func (c *Code) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	decoded, err := codeFromJSON(v)
+	if err != nil {
+		return err
+	}
+
+	*c = decoded
+	return nil
+}
+
+func codeFromJSON(v interface{}) (Code, error) {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return Code{}, errors.New("invalid JSON code: empty literal")
+		}
+		return Code{Length: 1, Literal: v}, nil
+
+	case []interface{}:
+		var items []Code
+		for _, item := range v {
+			sub, err := codeFromJSON(item)
+			if err != nil {
+				return Code{}, err
+			}
+			items = append(items, sub)
+		}
+		return newList(items), nil
+	}
+
+	return Code{}, fmt.Errorf("invalid JSON code: expected a string or an array, got %v", v)
+}
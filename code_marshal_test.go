@@ -0,0 +1,237 @@
+package gopush_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/DataWraith/gopush"
+)
+
+var codeMarshalPrograms = []string{
+	"",
+	"A",
+	"( )",
+	"1 2 INTEGER.+",
+	"( ( ( ) ) )",
+	"CODE.QUOTE ( INTEGER.DUP INTEGER.* ( 1 2 3 ) ) CODE.DO INTEGER.DUP INTEGER.DUP",
+	"( A ( B ( C D ) E ) F ) ( A ( B ) )",
+}
+
+// Tests the binary format of a small program
+func TestCodeMarshalBinary(t *testing.T) {
+	c, err := gopush.ParseCode("A ( B A )")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []byte{1, 2, 1, 'A', 1, 'B', 4, 1, 4, 3, 1}
+	if !bytes.Equal(b, expected) {
+		t.Errorf("expected %v, got %v", expected, b)
+	}
+
+	for n := range b {
+		var c2 gopush.Code
+		if err := c2.UnmarshalBinary(b[:n]); err == nil {
+			t.Errorf("expected an error while decoding %v", b[:n])
+		}
+	}
+
+	var c2 gopush.Code
+	if err := c2.UnmarshalBinary(append(b, 0)); err == nil {
+		t.Error("expected an error while decoding trailing data")
+	}
+}
+
+// Tests that deeply nested lists are rejected instead of exhausting the stack
+func TestCodeMarshalBinaryDepth(t *testing.T) {
+	nested := func(lists int) []byte {
+		b := []byte{1, 0}
+		for n := 1; n < lists; n++ {
+			b = append(b, 2)
+		}
+		return append(b, 0)
+	}
+
+	var c gopush.Code
+	if err := c.UnmarshalBinary(nested(10000)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := c.MarshalBinary(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	c = gopush.Code{Length: 1}
+	for n := 0; n < 10000; n++ {
+		c = gopush.Code{Length: c.Length + 1, List: []gopush.Code{c}}
+	}
+
+	if _, err := c.MarshalBinary(); err == nil || err.Error() != "code is nested deeper than 10000 lists" {
+		t.Errorf("expected nesting error, got %v", err)
+	}
+
+	if err := c.UnmarshalBinary(nested(10001)); err == nil || err.Error() != "invalid binary code: nested deeper than 10000 lists" {
+		t.Errorf("expected nesting error, got %v", err)
+	}
+
+	if err := c.UnmarshalBinary(nested(1000000)); err == nil {
+		t.Error("expected nesting error")
+	}
+}
+
+// Tests that Code is encoded as nested JSON arrays, also when it is part of
+// another value
+func TestCodeMarshalJSON(t *testing.T) {
+	c, err := gopush.ParseCode("1 ( 2 3 ) INTEGER.+")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, err := json.Marshal(map[string]gopush.Code{"program": c})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(b) != `{"program":["1",["2","3"],"INTEGER.+"]}` {
+		t.Errorf("unexpected encoding %s", b)
+	}
+
+	if _, err := json.Marshal(gopush.Code{Length: 1, Literal: "\xb7"}); err == nil {
+		t.Error("expected an error while encoding a literal that is not valid UTF-8")
+	}
+
+	for _, invalid := range []string{`1`, `["A", null]`, `[""]`, `{"A": "B"}`} {
+		var c2 gopush.Code
+		if err := json.Unmarshal([]byte(invalid), &c2); err == nil {
+			t.Errorf("expected an error while decoding %s", invalid)
+		}
+	}
+}
+
+func addCodeMarshalSeeds(f *testing.F) {
+	for _, program := range codeMarshalPrograms {
+		f.Add(program)
+	}
+}
+
+// parseFuzzProgram parses the fuzz input, skipping inputs that are not valid
+// programs.
+func parseFuzzProgram(t *testing.T, program string) gopush.Code {
+	c, err := gopush.ParseCode(program)
+	if err != nil {
+		t.Skip()
+	}
+	return c
+}
+
+// Tests that programs survive the binary encoding
+func FuzzCodeBinary(f *testing.F) {
+	addCodeMarshalSeeds(f)
+
+	f.Fuzz(func(t *testing.T, program string) {
+		c := parseFuzzProgram(t, program)
+
+		b, err := c.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var c2 gopush.Code
+		if err := c2.UnmarshalBinary(b); err != nil {
+			t.Fatalf("unexpected error while decoding %v: %v", b, err)
+		}
+
+		if !reflect.DeepEqual(c, c2) {
+			t.Errorf("expected %v to survive the binary encoding, got %v", c, c2)
+		}
+	})
+}
+
+// Tests that decoding arbitrary data does not panic, and that whatever is
+// decoded is encoded the same way again
+func FuzzCodeUnmarshalBinary(f *testing.F) {
+	for _, program := range codeMarshalPrograms {
+		c, _ := gopush.ParseCode(program)
+		b, _ := c.MarshalBinary()
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var c gopush.Code
+		if err := c.UnmarshalBinary(b); err != nil {
+			return
+		}
+
+		b2, err := c.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var c2 gopush.Code
+		if err := c2.UnmarshalBinary(b2); err != nil {
+			t.Fatalf("unexpected error while decoding %v: %v", b2, err)
+		}
+
+		if !reflect.DeepEqual(c, c2) {
+			t.Errorf("expected %v to survive the binary encoding, got %v", c, c2)
+		}
+	})
+}
+
+// Tests that programs survive the text encoding
+func FuzzCodeText(f *testing.F) {
+	addCodeMarshalSeeds(f)
+
+	f.Fuzz(func(t *testing.T, program string) {
+		c := parseFuzzProgram(t, program)
+
+		b, err := c.MarshalText()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var c2 gopush.Code
+		if err := c2.UnmarshalText(b); err != nil {
+			t.Fatalf("unexpected error while decoding %q: %v", b, err)
+		}
+
+		if !reflect.DeepEqual(c, c2) {
+			t.Errorf("expected %v to survive the text encoding, got %v", c, c2)
+		}
+	})
+}
+
+// Tests that programs survive the JSON encoding
+func FuzzCodeJSON(f *testing.F) {
+	addCodeMarshalSeeds(f)
+
+	f.Fuzz(func(t *testing.T, program string) {
+		if !utf8.ValidString(program) {
+			t.Skip()
+		}
+
+		c := parseFuzzProgram(t, program)
+
+		b, err := json.Marshal(c)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var c2 gopush.Code
+		if err := json.Unmarshal(b, &c2); err != nil {
+			t.Fatalf("unexpected error while decoding %s: %v", b, err)
+		}
+
+		if !reflect.DeepEqual(c, c2) {
+			t.Errorf("expected %v to survive the JSON encoding, got %v", c, c2)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package gopush
+
+// Walk calls f for every point of c in depth-first order, numbering the points
+// starting with c itself as point 0. The walk stops as soon as f returns false.
+// Walk returns false if it was stopped, and true otherwise.
+func (c Code) Walk(f func(point int, sub Code) bool) bool {
+	point := 0
+	return c.walk(&point, f)
+}
+
+func (c Code) walk(point *int, f func(int, Code) bool) bool {
+	if !f(*point, c) {
+		return false
+	}
+	*point++
+
+	if c.Literal != "" {
+		return true
+	}
+
+	for _, sl := range c.List {
+		if !sl.walk(point, f) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Points returns the number of points in c, counting c itself.
+func (c Code) Points() int {
+	if c.Literal != "" {
+		return 1
+	}
+
+	n := 1
+	for _, sl := range c.List {
+		n += sl.Points()
+	}
+
+	return n
+}
+
+// At returns the subtree of c at the given point, and whether c has that
+// point. The points are numbered as by Walk.
+func (c Code) At(point int) (Code, bool) {
+	if point == 0 {
+		return c, true
+	}
+
+	point--
+	for _, sl := range c.List {
+		n := sl.Points()
+		if point < n {
+			return sl.At(point)
+		}
+		point -= n
+	}
+
+	return Code{}, false
+}
+
+// Replace returns a copy of c in which the subtree at the given point has been
+// replaced by r, or c itself if c does not have that point. The lists of c are
+// not modified, and the Length of the copy is recomputed.
+func (c Code) Replace(point int, r Code) Code {
+	if point == 0 {
+		return r.Normalize()
+	}
+
+	point--
+	for j, sl := range c.List {
+		n := sl.Points()
+		if point < n {
+			list := make([]Code, len(c.List))
+			copy(list, c.List)
+			list[j] = sl.Replace(point, r)
+			return newList(list)
+		}
+		point -= n
+	}
+
+	return c
+}
+
+// Map returns a copy of c in which every atom has been replaced by the atoms
+// returned by f. The atoms are visited in depth-first order, and the Length of
+// the copy is recomputed.
+func (c Code) Map(f func(atom Code) []Code) Code {
+	if c.Literal != "" {
+		atoms := f(c)
+		if len(atoms) == 1 {
+			return atoms[0].Normalize()
+		}
+		return Code{List: atoms}.Normalize()
+	}
+
+	list := make([]Code, 0, len(c.List))
+	for _, sl := range c.List {
+		if sl.Literal != "" {
+			for _, atom := range f(sl) {
+				list = append(list, atom.Normalize())
+			}
+		} else {
+			list = append(list, sl.Map(f))
+		}
+	}
+
+	return newList(list)
+}
+
+// Normalize returns a copy of c with the Length recomputed the way ParseCode
+// does: an atom has length 1 and a list has the combined length of its
+// elements, where every sublist contributes one additional point for itself.
+func (c Code) Normalize() Code {
+	if c.Literal != "" {
+		c.Length = 1
+		return c
+	}
+
+	if c.List == nil {
+		return Code{}
+	}
+
+	list := make([]Code, len(c.List))
+	for j, sl := range c.List {
+		list[j] = sl.Normalize()
+	}
+
+	return newList(list)
+}
+
+// newList returns a list of the given items with the correct Length. The
+// items themselves are expected to have the correct Length already.
+func newList(items []Code) Code {
+	c := Code{List: items}
+	for _, item := range items {
+		c.Length++
+		if item.Literal == "" {
+			c.Length += item.Length
+		}
+	}
+
+	return c
+}
+
+// wrapIndex maps an arbitrary integer onto an index into n items the way the
+// indexing instructions do: modulo n, ignoring the sign.
+func wrapIndex(i int64, n int) int {
+	idx := i % int64(n)
+	if idx < 0 {
+		idx = -idx
+	}
+
+	return int(idx)
+}
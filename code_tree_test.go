@@ -0,0 +1,129 @@
+package gopush_test
+
+import (
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+func TestCodeWalk(t *testing.T) {
+	c := mustParse(t, "A ( B ( C ) ) D")
+
+	var visited []string
+	c.Walk(func(point int, sub gopush.Code) bool {
+		visited = append(visited, sub.String())
+		return true
+	})
+
+	expected := []string{"( A ( B ( C ) ) D )", "A", "( B ( C ) )", "B", "( C )", "C", "D"}
+	if len(visited) != len(expected) || len(visited) != c.Points() {
+		t.Fatalf("expected to visit %v, got %v", expected, visited)
+	}
+
+	for point, s := range visited {
+		if s != expected[point] {
+			t.Errorf("expected point %v to be %v, got %v", point, expected[point], s)
+		}
+
+		if sub, ok := c.At(point); !ok || sub.String() != s {
+			t.Errorf("expected At(%v) to return %v, got %v %v", point, s, sub, ok)
+		}
+	}
+
+	if _, ok := c.At(len(expected)); ok {
+		t.Error("expected At to fail beyond the last point")
+	}
+
+	n := 0
+	completed := c.Walk(func(point int, sub gopush.Code) bool {
+		n++
+		return point < 2
+	})
+
+	if completed || n != 3 {
+		t.Errorf("expected the walk to stop after 3 points, visited %v", n)
+	}
+}
+
+var codeReplaceTests = []struct {
+	program     string
+	point       int
+	replacement string
+	expected    string
+}{
+	{"A B", 0, "C", "C"},
+	{"A B", 1, "C", "( C B )"},
+	{"A ( B C ) D", 2, "( E ( F ) )", "( A ( E ( F ) ) D )"},
+	{"A ( B C ) D", 4, "( E )", "( A ( B ( E ) ) D )"},
+	{"A ( B C ) D", 6, "E", "( A ( B C ) D )"},
+}
+
+func TestCodeReplace(t *testing.T) {
+	for _, tt := range codeReplaceTests {
+		c := mustParse(t, tt.program)
+		r := mustParse(t, tt.replacement).List[0]
+
+		replaced := c.Replace(tt.point, r)
+
+		if replaced.String() != tt.expected {
+			t.Errorf("expected replacing point %v of %q with %q to give %v, got %v", tt.point, tt.program, tt.replacement, tt.expected, replaced)
+		}
+
+		checkLength(t, replaced)
+
+		if c.String() != mustParse(t, tt.program).String() {
+			t.Errorf("expected %q not to be modified, got %v", tt.program, c)
+		}
+	}
+}
+
+func TestCodeMapAndNormalize(t *testing.T) {
+	c := mustParse(t, "A ( B ( C ) ) D")
+
+	mapped := c.Map(func(atom gopush.Code) []gopush.Code {
+		switch atom.Literal {
+		case "A":
+			return nil
+		case "C":
+			return []gopush.Code{mustParse(t, "X Y")}
+		}
+		return []gopush.Code{atom}
+	})
+
+	if mapped.String() != "( ( B ( ( X Y ) ) ) D )" {
+		t.Errorf("unexpected result %v", mapped)
+	}
+
+	checkLength(t, mapped)
+
+	broken := mapped
+	broken.Length = 42
+	normalized := broken.Normalize()
+	if !normalized.Equal(mapped) || normalized.Length != mapped.Length {
+		t.Errorf("expected %v to have length %v after normalizing, got %v", broken, mapped.Length, normalized.Length)
+	}
+}
+
+// Checks the Length of the code the EXEC instructions build, which the test
+// suite cannot see because it ends up executed.
+func TestExecInstructionLengths(t *testing.T) {
+	for _, program := range []string{
+		"EXEC.S ( A ) ( B ) ( C )",
+		"EXEC.Y ( 1 )",
+		"3 EXEC.DO*COUNT ( INTEGER.POP )",
+		"3 EXEC.DO*TIMES ( 1 )",
+	} {
+		interpreter := newVariationInterpreter()
+		interpreter.Load(mustParse(t, program))
+
+		for n := 0; n < 10 && interpreter.Stacks["exec"].Len() > 0; n++ {
+			if err := interpreter.Step(); err != nil {
+				break
+			}
+
+			for _, item := range interpreter.Stacks["exec"].Stack {
+				checkLength(t, item.(gopush.Code))
+			}
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package gopush
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrorMetric determines how the difference between an output of a program and
+// the expected output is turned into an error.
+type ErrorMetric int
+
+const (
+	// AbsoluteError is the absolute difference of numbers. Booleans,
+	// strings and code have an error of 0 if they are equal and 1
+	// otherwise.
+	AbsoluteError ErrorMetric = iota
+
+	// SquaredError is the squared difference of numbers. Booleans,
+	// strings and code have an error of 0 if they are equal and 1
+	// otherwise.
+	SquaredError
+
+	// LevenshteinError is the edit distance between the textual
+	// representations of the values.
+	LevenshteinError
+)
+
+// Column describes how a column of a dataset maps onto a stack.
+type Column struct {
+	// The header of the CSV column or the key in the JSON objects
+	Name string
+
+	// The stack the value is pushed onto (for inputs) or expected on (for
+	// outputs). The values are parsed with ParseValue. The datasets returned
+	// by ReadCSVDataset and ReadJSONDataset hold the name in lower case.
+	Stack string
+
+	// Whether the column holds an expected output rather than an input
+	Output bool
+
+	// The error metric used if the column is an output
+	Metric ErrorMetric
+}
+
+// Dataset holds input/output examples that programs can be evaluated on.
+type Dataset struct {
+	Columns []Column
+
+	// Rows holds the parsed values of every example, in the same order as
+	// Columns.
+	Rows [][]interface{}
+
+	// The error assigned to an output that the program did not produce,
+	// because the stack it is expected on holds too few items.
+	MissingPenalty float64
+}
+
+// defaultMissingPenalty is the default error for missing outputs.
+const defaultMissingPenalty = 1000000
+
+// ReadCSVDataset reads a dataset from CSV data with a header row. Only the
+// columns named in columns are used.
+func ReadCSVDataset(r io.Reader, columns []Column) (*Dataset, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing header row")
+	}
+
+	header := make(map[string]int)
+	for idx, name := range records[0] {
+		header[strings.TrimSpace(name)] = idx
+	}
+
+	columns = normalizeColumns(columns)
+
+	// Check the columns even if there are no rows
+	indices := make([]int, len(columns))
+	for j, col := range columns {
+		idx, ok := header[col.Name]
+		if !ok {
+			return nil, fmt.Errorf("column %q not found", col.Name)
+		}
+		indices[j] = idx
+	}
+
+	d := &Dataset{Columns: columns, MissingPenalty: defaultMissingPenalty}
+
+	for n, record := range records[1:] {
+		row := make([]interface{}, len(columns))
+
+		for j, col := range columns {
+			v, err := ParseValue(col.Stack, strings.TrimSpace(record[indices[j]]))
+			if err != nil {
+				return nil, fmt.Errorf("row %v, column %q: %v", n+1, col.Name, err)
+			}
+			row[j] = v
+		}
+
+		d.Rows = append(d.Rows, row)
+	}
+
+	return d, nil
+}
+
+// ReadJSONDataset reads a dataset from a JSON array of objects, each of which
+// is one example. Only the keys named in columns are used.
+func ReadJSONDataset(r io.Reader, columns []Column) (*Dataset, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var examples []map[string]interface{}
+	if err := dec.Decode(&examples); err != nil {
+		return nil, err
+	}
+
+	columns = normalizeColumns(columns)
+	d := &Dataset{Columns: columns, MissingPenalty: defaultMissingPenalty}
+
+	for n, example := range examples {
+		row := make([]interface{}, len(columns))
+
+		for j, col := range columns {
+			raw, ok := example[col.Name]
+			if !ok {
+				return nil, fmt.Errorf("example %v: key %q not found", n, col.Name)
+			}
+
+			var s string
+			switch v := raw.(type) {
+			case json.Number:
+				s = v.String()
+			case string:
+				s = v
+			case bool:
+				s = strconv.FormatBool(v)
+			default:
+				return nil, fmt.Errorf("example %v, key %q: unsupported value %v", n, col.Name, raw)
+			}
+
+			v, err := ParseValue(col.Stack, s)
+			if err != nil {
+				return nil, fmt.Errorf("example %v, key %q: %v", n, col.Name, err)
+			}
+			row[j] = v
+		}
+
+		d.Rows = append(d.Rows, row)
+	}
+
+	return d, nil
+}
+
+// normalizeColumns returns a copy of columns with the stack names in lower
+// case.
+func normalizeColumns(columns []Column) []Column {
+	normalized := make([]Column, len(columns))
+	for j, col := range columns {
+		col.Stack = strings.ToLower(col.Stack)
+		normalized[j] = col
+	}
+	return normalized
+}
+
+// Validate returns an error if a column refers to a stack that the given
+// Interpreter does not have. The inputs of such columns would not be pushed,
+// and their outputs would always be missing.
+func (d *Dataset) Validate(i *Interpreter) error {
+	for _, col := range d.Columns {
+		if _, ok := i.Stacks[col.Stack]; !ok {
+			return fmt.Errorf("column %q uses stack %q, which the interpreter does not have", col.Name, col.Stack)
+		}
+	}
+
+	return nil
+}
+
+// ReadDatasetFromFile reads the given dataset file. Files ending in .json are
+// read with ReadJSONDataset, all others with ReadCSVDataset.
+func ReadDatasetFromFile(filename string, columns []Column) (*Dataset, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		return ReadJSONDataset(f, columns)
+	}
+
+	return ReadCSVDataset(f, columns)
+}
+
+// ParseValue parses s as a value of the given stack. Values for the integer,
+// float and boolean stacks are parsed as such, values for the code and exec
+// stacks are parsed with ParseCode, names are lowercased and values for all
+// other stacks are returned as strings. The stack name is not case-sensitive.
+func ParseValue(stack string, s string) (interface{}, error) {
+	switch strings.ToLower(stack) {
+	case "integer":
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as integer", s)
+		}
+		return i, nil
+
+	case "float":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as float", s)
+		}
+		return f, nil
+
+	case "boolean":
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as boolean", s)
+		}
+		return b, nil
+
+	case "code", "exec":
+		return ParseCode(s)
+
+	case "name":
+		return strings.ToLower(s), nil
+	}
+
+	return s, nil
+}
+
+// FitnessCases returns one FitnessCase per example of the dataset. Each case
+// pushes the inputs onto their stacks in column order, so the last input
+// column ends up on top. Inputs for stacks the Interpreter does not have are
+// left out, see Validate. The outputs expected on the same stack are compared
+// to the stack items from the top down, in column order. The error of a case
+// is the sum of the errors of its outputs.
+func (d *Dataset) FitnessCases() []FitnessCase {
+	cases := make([]FitnessCase, len(d.Rows))
+	for j := range d.Rows {
+		cases[j] = datasetCase{dataset: d, row: j}
+	}
+	return cases
+}
+
+type datasetCase struct {
+	dataset *Dataset
+	row     int
+}
+
+func (dc datasetCase) Setup(i *Interpreter) {
+	for j, col := range dc.dataset.Columns {
+		if col.Output {
+			continue
+		}
+
+		if s, ok := i.Stacks[col.Stack]; ok {
+			s.Push(dc.dataset.Rows[dc.row][j])
+		}
+	}
+}
+
+// String describes the case by its columns, values and missing penalty, so
+// that it can be identified by an EvaluationCache.
+func (dc datasetCase) String() string {
+	return fmt.Sprintf("%v %#v %v", dc.dataset.Columns, dc.dataset.Rows[dc.row], dc.dataset.MissingPenalty)
+}
+
+func (dc datasetCase) Error(i *Interpreter, err error) float64 {
+	total := 0.0
+	depth := make(map[string]int64)
+
+	for j, col := range dc.dataset.Columns {
+		if !col.Output {
+			continue
+		}
+
+		depth[col.Stack]++
+
+		if !i.StackOK(col.Stack, depth[col.Stack]) {
+			total += dc.dataset.MissingPenalty
+			continue
+		}
+
+		s := i.Stacks[col.Stack].Stack
+		got := s[len(s)-int(depth[col.Stack])]
+
+		e := outputError(col.Metric, got, dc.dataset.Rows[dc.row][j])
+		if math.IsNaN(e) || math.IsInf(e, 0) {
+			e = dc.dataset.MissingPenalty
+		}
+
+		total += e
+	}
+
+	return total
+}
+
+func outputError(metric ErrorMetric, got, want interface{}) float64 {
+	if metric == LevenshteinError {
+		return float64(levenshtein(fmt.Sprint(got), fmt.Sprint(want)))
+	}
+
+	var diff float64
+
+	switch w := want.(type) {
+	case int64:
+		g, ok := got.(int64)
+		if !ok {
+			return math.NaN()
+		}
+		diff = float64(g) - float64(w)
+
+	case float64:
+		g, ok := got.(float64)
+		if !ok {
+			return math.NaN()
+		}
+		diff = g - w
+
+	default:
+		if !reflect.DeepEqual(got, want) {
+			diff = 1
+		}
+	}
+
+	if metric == SquaredError {
+		return diff * diff
+	}
+
+	return math.Abs(diff)
+}
+
+// levenshtein returns the edit distance between s1 and s2.
+func levenshtein(s1, s2 string) int {
+	r1, r2 := []rune(s1), []rune(s2)
+
+	prev := make([]int, len(r2)+1)
+	cur := make([]int, len(r2)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(r1); i++ {
+		cur[0] = i
+		for j := 1; j <= len(r2); j++ {
+			cost := 1
+			if r1[i-1] == r2[j-1] {
+				cost = 0
+			}
+
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(r2)]
+}
@@ -0,0 +1,124 @@
+package gopush_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+const sumCSV = `x,y,sum
+1,2,3
+10, 5,15
+-4,4,0
+`
+
+func TestCSVDataset(t *testing.T) {
+	columns := []gopush.Column{
+		{Name: "x", Stack: "integer"},
+		{Name: "y", Stack: "integer"},
+		{Name: "sum", Stack: "integer", Output: true, Metric: gopush.SquaredError},
+	}
+
+	d, err := gopush.ReadCSVDataset(strings.NewReader(sumCSV), columns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(d.Rows[1], []interface{}{int64(10), int64(5), int64(15)}) {
+		t.Errorf("unexpected second row: %v", d.Rows[1])
+	}
+
+	programs := []gopush.Code{
+		mustParse(t, "INTEGER.+"),
+		mustParse(t, "INTEGER.-"),
+		mustParse(t, "INTEGER.FLUSH"),
+	}
+
+	expected := [][]float64{
+		{0, 0, 0},
+		{16, 100, 64},
+		{d.MissingPenalty, d.MissingPenalty, d.MissingPenalty},
+	}
+
+	evaluator := gopush.Evaluator{Options: gopush.DefaultOptions}
+	errors, err := evaluator.Evaluate(context.Background(), programs, d.FitnessCases())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(errors, expected) {
+		t.Errorf("expected errors %v, got %v", expected, errors)
+	}
+}
+
+const greetingJSON = `[
+	{"name": "World", "excited": true, "greeting": "Hello, World!"},
+	{"name": "Go", "excited": false, "greeting": "Hello, Go."}
+]`
+
+func TestJSONDatasetWithStringStack(t *testing.T) {
+	columns := []gopush.Column{
+		{Name: "name", Stack: "string"},
+		{Name: "excited", Stack: "boolean"},
+		{Name: "greeting", Stack: "STRING", Output: true, Metric: gopush.LevenshteinError},
+	}
+
+	d, err := gopush.ReadJSONDataset(strings.NewReader(greetingJSON), columns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d.Columns[2].Stack != "string" {
+		t.Errorf("expected the stack name to be lower case, got %q", d.Columns[2].Stack)
+	}
+
+	interpreter := gopush.NewInterpreter(gopush.DefaultOptions)
+
+	if err := d.Validate(interpreter); err == nil || err.Error() != "column \"name\" uses stack \"string\", which the interpreter does not have" {
+		t.Errorf("expected missing stack error, got %v", err)
+	}
+
+	stringStack := &gopush.Stack{Functions: make(map[string]func())}
+	stringStack.Functions["greet"] = func() {
+		name := interpreter.Stacks["string"].Pop().(string)
+		interpreter.Stacks["string"].Push("Hello, " + name + "!")
+	}
+
+	interpreter.Options.RegisterStack("string", stringStack)
+	interpreter.RegisterStack("string", stringStack)
+
+	if err := d.Validate(interpreter); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var errors []float64
+	for _, fc := range d.FitnessCases() {
+		fc.Setup(interpreter)
+		err := interpreter.Run("STRING.GREET")
+		errors = append(errors, fc.Error(interpreter, err))
+		interpreter.Reset(1)
+	}
+
+	if !reflect.DeepEqual(errors, []float64{0, 1}) {
+		t.Errorf("expected errors [0 1], got %v", errors)
+	}
+}
+
+func TestDatasetErrors(t *testing.T) {
+	columns := []gopush.Column{{Name: "z", Stack: "integer"}}
+	if _, err := gopush.ReadCSVDataset(strings.NewReader(sumCSV), columns); err == nil || err.Error() != "column \"z\" not found" {
+		t.Errorf("expected missing column error, got %v", err)
+	}
+
+	if _, err := gopush.ReadCSVDataset(strings.NewReader("x,y,sum\n"), columns); err == nil || err.Error() != "column \"z\" not found" {
+		t.Errorf("expected missing column error without rows, got %v", err)
+	}
+
+	columns = []gopush.Column{{Name: "name", Stack: "integer"}}
+	if _, err := gopush.ReadJSONDataset(strings.NewReader(greetingJSON), columns); err == nil || err.Error() != "example 0, key \"name\": could not parse \"World\" as integer" {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
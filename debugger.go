@@ -0,0 +1,204 @@
+package gopush
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Breakpoint decides whether a Debugger should stop. It is consulted after
+// every executed item, and given the item that is going to be executed next
+// (or the empty Code if the EXEC stack is empty).
+type Breakpoint interface {
+	Hit(i *Interpreter, next Code) bool
+	String() string
+}
+
+type instructionBreakpoint string
+
+// InstructionBreakpoint returns a Breakpoint that stops before the given
+// instruction (or literal) is executed. Instruction names are compared
+// case-insensitively.
+func InstructionBreakpoint(name string) Breakpoint {
+	return instructionBreakpoint(strings.ToUpper(name))
+}
+
+func (b instructionBreakpoint) Hit(i *Interpreter, next Code) bool {
+	return next.Literal != "" && strings.ToUpper(next.Literal) == string(b)
+}
+
+func (b instructionBreakpoint) String() string {
+	return "instruction " + string(b)
+}
+
+type stepBreakpoint int
+
+// StepBreakpoint returns a Breakpoint that stops once the given number of
+// items has been executed.
+func StepBreakpoint(step int) Breakpoint {
+	return stepBreakpoint(step)
+}
+
+func (b stepBreakpoint) Hit(i *Interpreter, next Code) bool {
+	return i.numEvalPush == int(b)
+}
+
+func (b stepBreakpoint) String() string {
+	return fmt.Sprintf("step %d", int(b))
+}
+
+type stackDepthBreakpoint struct {
+	stack string
+	op    string
+	depth int64
+}
+
+// StackDepthBreakpoint returns a Breakpoint that stops when the depth of the
+// given stack compares to depth as described by op, which must be one of <,
+// <=, =, !=, >= and >.
+func StackDepthBreakpoint(stack string, op string, depth int64) (Breakpoint, error) {
+	switch op {
+	case "<", "<=", "=", "!=", ">=", ">":
+	default:
+		return nil, fmt.Errorf("unknown comparison %q", op)
+	}
+
+	return stackDepthBreakpoint{stack: strings.ToLower(stack), op: op, depth: depth}, nil
+}
+
+func (b stackDepthBreakpoint) Hit(i *Interpreter, next Code) bool {
+	s, ok := i.Stacks[b.stack]
+	if !ok {
+		return false
+	}
+
+	switch b.op {
+	case "<":
+		return s.Len() < b.depth
+	case "<=":
+		return s.Len() <= b.depth
+	case "=":
+		return s.Len() == b.depth
+	case "!=":
+		return s.Len() != b.depth
+	case ">=":
+		return s.Len() >= b.depth
+	}
+
+	return s.Len() > b.depth
+}
+
+func (b stackDepthBreakpoint) String() string {
+	return fmt.Sprintf("depth %s %s %d", b.stack, b.op, b.depth)
+}
+
+type definitionBreakpoint struct {
+	name       string
+	seen       bool
+	defined    bool
+	definition Code
+}
+
+// DefinitionBreakpoint returns a Breakpoint that stops right after the given
+// name has been defined or redefined to a different value.
+func DefinitionBreakpoint(name string) Breakpoint {
+	return &definitionBreakpoint{name: strings.ToLower(name)}
+}
+
+func (b *definitionBreakpoint) Hit(i *Interpreter, next Code) bool {
+	d, defined := i.Definitions[b.name]
+
+	changed := b.seen && (defined != b.defined || !d.Equal(b.definition))
+
+	b.seen = true
+	b.defined = defined
+	b.definition = d
+
+	return changed
+}
+
+func (b *definitionBreakpoint) String() string {
+	return "define " + b.name
+}
+
+// Debugger runs a program on an Interpreter step by step and stops at
+// breakpoints. While the Debugger is stopped, the stacks and definitions of
+// the Interpreter can be inspected and modified freely.
+type Debugger struct {
+	Interpreter *Interpreter
+	Breakpoints []Breakpoint
+}
+
+// NewDebugger returns a new Debugger for the given Interpreter.
+func NewDebugger(i *Interpreter) *Debugger {
+	return &Debugger{Interpreter: i}
+}
+
+// Load loads the given program for debugging. See Interpreter.Load.
+func (d *Debugger) Load(c Code) {
+	d.Interpreter.Load(c)
+	d.checkBreakpoints()
+}
+
+// Done returns whether the program has finished, i.e. the EXEC stack is empty.
+func (d *Debugger) Done() bool {
+	return d.Interpreter.Stacks["exec"].Len() == 0
+}
+
+// Steps returns the number of items executed since the program was loaded.
+func (d *Debugger) Steps() int {
+	return d.Interpreter.numEvalPush
+}
+
+// Next returns the item that will be executed next, or the empty Code if the
+// program has finished.
+func (d *Debugger) Next() Code {
+	if d.Done() {
+		return Code{}
+	}
+
+	return d.Interpreter.Stacks["exec"].Peek().(Code)
+}
+
+// Step executes a single item, ignoring breakpoints. It returns the first
+// breakpoint that is hit afterwards, if any.
+func (d *Debugger) Step() (Breakpoint, error) {
+	if err := d.Interpreter.Step(); err != nil {
+		return nil, err
+	}
+
+	return d.checkBreakpoints(), nil
+}
+
+// Continue executes items until a breakpoint is hit, the program finishes or
+// an error occurs. It returns the breakpoint that was hit, or nil if the
+// program finished.
+func (d *Debugger) Continue() (Breakpoint, error) {
+	for !d.Done() {
+		bp, err := d.Step()
+		if err != nil || bp != nil {
+			return bp, err
+		}
+	}
+
+	return nil, nil
+}
+
+// Define binds the given name to the given code, as if the program had done
+// so.
+func (d *Debugger) Define(name string, c Code) {
+	d.Interpreter.define(strings.ToLower(name), c)
+}
+
+// checkBreakpoints returns the first breakpoint that is hit. All breakpoints
+// are consulted, so stateful breakpoints stay up to date.
+func (d *Debugger) checkBreakpoints() (hit Breakpoint) {
+	next := d.Next()
+
+	for _, bp := range d.Breakpoints {
+		if bp.Hit(d.Interpreter, next) && hit == nil {
+			hit = bp
+		}
+	}
+
+	return hit
+}
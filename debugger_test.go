@@ -0,0 +1,55 @@
+package gopush_test
+
+import (
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+func TestDebuggerBreakpoints(t *testing.T) {
+	interpreter := gopush.NewInterpreter(gopush.DefaultOptions)
+	debugger := gopush.NewDebugger(interpreter)
+
+	depth, err := gopush.StackDepthBreakpoint("integer", ">=", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	debugger.Breakpoints = []gopush.Breakpoint{
+		gopush.DefinitionBreakpoint("X"),
+		gopush.InstructionBreakpoint("integer.+"),
+		depth,
+		gopush.StepBreakpoint(100),
+	}
+
+	debugger.Load(mustParse(t, "( X CODE.QUOTE 7 CODE.DEFINE ) 1 2 INTEGER.+ X X"))
+
+	expected := []struct {
+		breakpoint gopush.Breakpoint
+		steps      int
+	}{
+		{debugger.Breakpoints[0], 5},
+		{debugger.Breakpoints[1], 7},
+		{debugger.Breakpoints[2], 12},
+		{nil, 12},
+	}
+
+	for _, e := range expected {
+		bp, err := debugger.Continue()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if bp != e.breakpoint || debugger.Steps() != e.steps {
+			t.Errorf("expected to stop at %v after %v steps, stopped at %v after %v steps", e.breakpoint, e.steps, bp, debugger.Steps())
+		}
+	}
+
+	if !debugger.Done() {
+		t.Error("expected the program to be finished")
+	}
+
+	if _, err := gopush.StackDepthBreakpoint("integer", "~", 3); err == nil {
+		t.Error("expected an error for an unknown comparison")
+	}
+}
@@ -0,0 +1,144 @@
+package gopush
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cryptix/goremutake"
+)
+
+// ERC generates ephemeral random constants for random code generation. All
+// randomness must come from the Rand of the given Interpreter, so that
+// generated code is reproducible from the RandomSeed.
+type ERC interface {
+	Generate(i *Interpreter) Code
+}
+
+// ERCFunc adapts an ordinary function to the ERC interface.
+type ERCFunc func(i *Interpreter) Code
+
+// Generate calls f(i).
+func (f ERCFunc) Generate(i *Interpreter) Code {
+	return f(i)
+}
+
+// RegisterERC makes random code generation produce constants from the given
+// generator under the pseudo-instruction NAME-ERC, e.g. STRING-ERC for the
+// name "string". The pseudo-instruction is chosen like any other instruction,
+// with a weight of Options.ERCWeight unless Options.InstructionWeights holds
+// one. Registering a generator under an existing name, e.g. "integer",
+// replaces the existing generator.
+func (i *Interpreter) RegisterERC(name string, g ERC) {
+	instr := strings.ToUpper(name) + "-ERC"
+
+	if _, ok := i.ercs[instr]; !ok {
+		i.listOfInstructions = append(i.listOfInstructions, instr)
+		sort.Strings(i.listOfInstructions)
+		i.cumulativeWeights = nil
+	}
+
+	i.ercs[instr] = g
+}
+
+// isERC returns whether instr is the pseudo-instruction of a registered ERC.
+func (i *Interpreter) isERC(instr string) bool {
+	_, ok := i.ercs[instr]
+	return ok
+}
+
+// IntegerRangeERC returns an ERC that generates integers uniformly from the
+// range [min, max].
+func IntegerRangeERC(min, max int64) ERC {
+	return ERCFunc(func(i *Interpreter) Code {
+		return Code{Length: 1, Literal: fmt.Sprint(i.Rand.Int63n(max+1-min) + min)}
+	})
+}
+
+// IntegerSetERC returns an ERC that chooses integers uniformly from the given
+// values.
+func IntegerSetERC(values []int64) ERC {
+	values = append([]int64(nil), values...)
+
+	return ERCFunc(func(i *Interpreter) Code {
+		return Code{Length: 1, Literal: fmt.Sprint(values[i.Rand.Intn(len(values))])}
+	})
+}
+
+// FloatRangeERC returns an ERC that generates floats uniformly from the range
+// [min, max).
+func FloatRangeERC(min, max float64) ERC {
+	return ERCFunc(func(i *Interpreter) Code {
+		return Code{Length: 1, Literal: floatLiteral(i.Rand.Float64()*(max-min) + min)}
+	})
+}
+
+// FloatSetERC returns an ERC that chooses floats uniformly from the given
+// values.
+func FloatSetERC(values []float64) ERC {
+	values = append([]float64(nil), values...)
+
+	return ERCFunc(func(i *Interpreter) Code {
+		return Code{Length: 1, Literal: floatLiteral(values[i.Rand.Intn(len(values))])}
+	})
+}
+
+// GaussianFloatERC returns an ERC that generates normally distributed floats
+// with the given mean and standard deviation.
+func GaussianFloatERC(mean, stddev float64) ERC {
+	return ERCFunc(func(i *Interpreter) Code {
+		return Code{Length: 1, Literal: floatLiteral(i.Rand.NormFloat64()*stddev + mean)}
+	})
+}
+
+// floatLiteral formats f so that it is parsed back as a float.
+func floatLiteral(f float64) string {
+	s := fmt.Sprint(f)
+	if !strings.ContainsAny(s, ".eEnN") {
+		s += ".0"
+	}
+	return s
+}
+
+// integerERC generates integers as configured in the Options of the
+// Interpreter: from ERCIntegerSet if it is not empty and from the range
+// [MinRandomInteger, MaxRandomInteger] otherwise.
+var integerERC = ERCFunc(func(i *Interpreter) Code {
+	if len(i.Options.ERCIntegerSet) > 0 {
+		return IntegerSetERC(i.Options.ERCIntegerSet).Generate(i)
+	}
+
+	return IntegerRangeERC(i.Options.MinRandomInteger, i.Options.MaxRandomInteger).Generate(i)
+})
+
+// floatERC generates floats as configured in the Options of the Interpreter:
+// from ERCFloatSet if it is not empty, from a normal distribution if
+// ERCFloatStdDev is positive and from the range [MinRandomFloat,
+// MaxRandomFloat) otherwise.
+var floatERC = ERCFunc(func(i *Interpreter) Code {
+	switch {
+	case len(i.Options.ERCFloatSet) > 0:
+		return FloatSetERC(i.Options.ERCFloatSet).Generate(i)
+	case i.Options.ERCFloatStdDev > 0:
+		return GaussianFloatERC(i.Options.ERCFloatMean, i.Options.ERCFloatStdDev).Generate(i)
+	}
+
+	return FloatRangeERC(i.Options.MinRandomFloat, i.Options.MaxRandomFloat).Generate(i)
+})
+
+// nameERC generates a new name with probability NewERCNameProbabilty, and
+// reuses one of the names generated so far otherwise.
+var nameERC = ERCFunc(func(i *Interpreter) Code {
+	var name string
+
+	if i.Rand.Float64() < i.Options.NewERCNameProbabilty || i.numNamesGenerated == 0 {
+		// Generate a new random NAME
+		name = goremutake.Encode(i.numNamesGenerated)
+		i.numNamesGenerated++
+	} else {
+		// Use a random, already generated NAME
+		name = goremutake.Encode(uint(i.Rand.Intn(int(i.numNamesGenerated))))
+	}
+
+	return Code{Length: 1, Literal: name}
+})
@@ -0,0 +1,136 @@
+package gopush_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+// literals returns the literals contained in c
+func literals(c gopush.Code) []string {
+	if c.Literal != "" {
+		return []string{c.Literal}
+	}
+
+	var l []string
+	for _, sub := range c.List {
+		l = append(l, literals(sub)...)
+	}
+	return l
+}
+
+// Tests that Options without an ERCWeight, e.g. because they were built by
+// hand, still generate constants
+func TestUnsetERCWeight(t *testing.T) {
+	options := gopush.DefaultOptions
+	options.ERCWeight = 0
+	options.RandomSeed = 1138
+
+	numbers := 0
+	for _, l := range literals(gopush.NewInterpreter(options).RandomCode(100)) {
+		if _, err := strconv.ParseFloat(l, 64); err == nil {
+			numbers++
+		}
+	}
+
+	if numbers == 0 {
+		t.Error("expected constants to be generated")
+	}
+
+	if !strings.Contains(options.String(), "ERC-WEIGHT 1.0\n") {
+		t.Errorf("expected the default ERC weight to be written, got\n%s", options.String())
+	}
+}
+
+func TestERCOptions(t *testing.T) {
+	tests := []struct {
+		config string
+		ok     func(f float64) bool
+	}{
+		{"type integer\nerc-integer-set 0 1 2 10", func(f float64) bool { return f == 0 || f == 1 || f == 2 || f == 10 }},
+		{"type float\nerc-float-set 0.5 -2", func(f float64) bool { return f == 0.5 || f == -2 }},
+		{"type float\nerc-float-mean 100\nerc-float-stddev 0.01", func(f float64) bool { return f > 99 && f < 101 }},
+	}
+
+	for _, test := range tests {
+		options, err := gopush.ParseOptions(test.config + "\nrandom-seed 1138\nerc-weight 1000")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		interpreter := gopush.NewInterpreter(options)
+
+		numbers := 0
+		for _, l := range literals(interpreter.RandomCode(100)) {
+			f, err := strconv.ParseFloat(l, 64)
+			if err != nil {
+				continue
+			}
+
+			numbers++
+			if !test.ok(f) {
+				t.Errorf("%q: unexpected constant %v", test.config, l)
+			}
+		}
+
+		if numbers == 0 {
+			t.Errorf("%q: expected constants to be generated", test.config)
+		}
+	}
+}
+
+func TestCustomERC(t *testing.T) {
+	options, _ := gopush.ParseOptions("type integer\ntype code\ninstruction integer.+\ninstruction code.instructions\ninstruction string.dup\nerc-weight 10\nrandom-seed 1138")
+
+	newInterpreter := func() *gopush.Interpreter {
+		interpreter := gopush.NewInterpreter(options)
+
+		s := &gopush.Stack{
+			Functions: map[string]func(){"dup": func() {}},
+			ParseLiteral: func(literal string) (interface{}, bool) {
+				if len(literal) < 2 || !strings.HasPrefix(literal, `"`) || !strings.HasSuffix(literal, `"`) {
+					return nil, false
+				}
+				return literal[1 : len(literal)-1], true
+			},
+		}
+		interpreter.RegisterStack("string", s)
+
+		words := []string{"foo", "bar", "baz"}
+		interpreter.RegisterERC("string", gopush.ERCFunc(func(i *gopush.Interpreter) gopush.Code {
+			return gopush.Code{Length: 1, Literal: `"` + words[i.Rand.Intn(len(words))] + `"`}
+		}))
+
+		return interpreter
+	}
+
+	i1, i2 := newInterpreter(), newInterpreter()
+	c1, c2 := i1.RandomCode(50), i2.RandomCode(50)
+
+	if c1.String() != c2.String() {
+		t.Errorf("expected the same code from the same seed, got %v and %v", c1, c2)
+	}
+
+	if !strings.Contains(c1.String(), `"`) {
+		t.Fatalf("expected string constants in %v", c1)
+	}
+
+	if err := i1.RunCode(c1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if i1.Stacks["string"].Len() == 0 {
+		t.Errorf("expected string constants to be pushed onto the string stack")
+	}
+
+	if err := i2.Run("CODE.INSTRUCTIONS"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	instructions := i2.Stacks["code"].Peek().(gopush.Code).String()
+	if strings.Contains(instructions, "ERC") || !strings.Contains(instructions, "STRING.DUP") {
+		t.Errorf("expected CODE.INSTRUCTIONS to list STRING.DUP but no ERCs, got %v", instructions)
+	}
+}
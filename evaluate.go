@@ -0,0 +1,177 @@
+package gopush
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// FitnessCase is a single test case that programs are evaluated on.
+type FitnessCase interface {
+	// Setup prepares the Interpreter before the program is run, usually by
+	// pushing the inputs of the case onto their stacks.
+	Setup(i *Interpreter)
+
+	// Error returns the error of the program on this case. It is given the
+	// Interpreter after the program was run and the error returned by
+	// RunCode.
+	Error(i *Interpreter, err error) float64
+}
+
+// Evaluator evaluates programs on a set of fitness cases using a pool of
+// Interpreters running in parallel.
+type Evaluator struct {
+	// The Options used to create the Interpreters. The random number
+	// generator is reseeded for every program with a seed derived from
+	// Options.RandomSeed and the program itself, so the results do not
+	// depend on which worker evaluates a program. If RandomSeed is 0, a
+	// random base seed is chosen for every call to Evaluate.
+	Options Options
+
+	// The number of Interpreters evaluating programs in parallel. If it is
+	// less than 1, runtime.NumCPU() Interpreters are used.
+	Workers int
+
+	// Prepare, if set, is called for every newly created Interpreter, e.g.
+	// to register custom stacks.
+	Prepare func(i *Interpreter)
+
+	// Cache, if set, holds the errors of programs evaluated before. Cached
+	// programs are not evaluated again, and programs that appear more than
+	// once are evaluated once, which counts as cache hits. The cache does
+	// not know about Prepare, so it must not be shared between Evaluators
+	// that prepare their Interpreters differently. The keys include the
+	// random base seed, so if Options.RandomSeed is 0, the errors cached
+	// by one call are not used by later ones.
+	Cache *EvaluationCache
+}
+
+// Evaluate runs every program on every fitness case and returns the error
+// vectors of the programs, one entry per fitness case. Every case is run on a
+// freshly reset Interpreter. If ctx is cancelled before all programs have been
+// evaluated, the context's error is returned.
+func (e Evaluator) Evaluate(ctx context.Context, programs []Code, cases []FitnessCase) ([][]float64, error) {
+	workers := e.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	options := e.Options
+	if options.RandomSeed == 0 {
+		options.RandomSeed = rand.Int63()
+	}
+
+	errors := make([][]float64, len(programs))
+	jobs := make(chan int)
+
+	// pending lists the programs to evaluate. The others are taken from
+	// the cache, or are duplicates of pending programs.
+	pending := make([]int, 0, len(programs))
+	var keys []EvaluationKey
+	duplicates := make(map[int]int) // index of the duplicate -> index of the pending program
+
+	if e.Cache == nil {
+		for p := range programs {
+			pending = append(pending, p)
+		}
+	} else {
+		keys = make([]EvaluationKey, len(programs))
+		first := make(map[EvaluationKey]int)
+
+		casesKey := casesHash(cases)
+		optionsKey := hashString(options.String())
+
+		for p, program := range programs {
+			keys[p] = EvaluationKey{Program: program.Hash(), Cases: casesKey, Options: optionsKey}
+
+			if f, ok := first[keys[p]]; ok {
+				duplicates[p] = f
+				continue
+			}
+
+			if cached, ok := e.Cache.Get(keys[p]); ok {
+				errors[p] = cached
+				continue
+			}
+
+			first[keys[p]] = p
+			pending = append(pending, p)
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			interpreter := NewInterpreter(options)
+			if e.Prepare != nil {
+				e.Prepare(interpreter)
+			}
+
+			for p := range jobs {
+				seed := programSeed(options.RandomSeed, programs[p])
+				errors[p] = evaluateProgram(ctx, interpreter, seed, programs[p], cases)
+			}
+		}()
+	}
+
+feed:
+	for _, p := range pending {
+		select {
+		case jobs <- p:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if e.Cache != nil {
+		for _, p := range pending {
+			e.Cache.Put(keys[p], errors[p])
+		}
+
+		for p, f := range duplicates {
+			errors[p] = append([]float64(nil), errors[f]...)
+		}
+		e.Cache.countHits(len(duplicates))
+	}
+
+	return errors, nil
+}
+
+func evaluateProgram(ctx context.Context, interpreter *Interpreter, seed int64, program Code, cases []FitnessCase) []float64 {
+	errors := make([]float64, len(cases))
+
+	for c, fc := range cases {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		interpreter.Reset(seed)
+		fc.Setup(interpreter)
+		err := interpreter.RunCode(program)
+		errors[c] = fc.Error(interpreter, err)
+	}
+
+	return errors
+}
+
+// programSeed derives the seed used to evaluate the given program from the
+// base seed.
+func programSeed(base int64, program Code) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(program.String()))
+	return base ^ int64(h.Sum64())
+}
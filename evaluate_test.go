@@ -0,0 +1,89 @@
+package gopush_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+// doublingCase expects the program to double the integer on top of the stack
+type doublingCase int64
+
+func (d doublingCase) Setup(i *gopush.Interpreter) {
+	i.Stacks["integer"].Push(int64(d))
+}
+
+func (d doublingCase) Error(i *gopush.Interpreter, err error) float64 {
+	if err != nil || !i.StackOK("integer", 1) {
+		return 1000
+	}
+
+	return math.Abs(float64(i.Stacks["integer"].Peek().(int64) - 2*int64(d)))
+}
+
+var doublingCases = []gopush.FitnessCase{doublingCase(1), doublingCase(2), doublingCase(-5)}
+
+func TestEvaluator(t *testing.T) {
+	programs := []gopush.Code{
+		mustParse(t, "INTEGER.DUP INTEGER.+"),
+		mustParse(t, "2 INTEGER.*"),
+		mustParse(t, "INTEGER.DUP"),
+		mustParse(t, "INTEGER.POP"),
+	}
+
+	expected := [][]float64{
+		{0, 0, 0},
+		{0, 0, 0},
+		{1, 2, 5},
+		{1000, 1000, 1000},
+	}
+
+	evaluator := gopush.Evaluator{Options: gopush.DefaultOptions, Workers: 2}
+
+	errors, err := evaluator.Evaluate(context.Background(), programs, doublingCases)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(errors, expected) {
+		t.Errorf("expected errors %v, got %v", expected, errors)
+	}
+}
+
+func TestEvaluatorIsDeterministic(t *testing.T) {
+	options := gopush.DefaultOptions
+	options.RandomSeed = 1138
+
+	interpreter := gopush.NewInterpreter(options)
+
+	programs := make([]gopush.Code, 50)
+	for j := range programs {
+		programs[j] = mustParse(t, "INTEGER.RAND INTEGER.+ 10 CODE.RAND CODE.DO")
+		programs[j].List = append(programs[j].List, interpreter.RandomCode(10))
+	}
+
+	e1 := gopush.Evaluator{Options: options, Workers: 1}
+	e8 := gopush.Evaluator{Options: options, Workers: 8}
+
+	errors1, _ := e1.Evaluate(context.Background(), programs, doublingCases)
+	errors8, _ := e8.Evaluate(context.Background(), programs, doublingCases)
+
+	if !reflect.DeepEqual(errors1, errors8) {
+		t.Error("expected the errors not to depend on the number of workers")
+	}
+}
+
+func TestEvaluatorCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	evaluator := gopush.Evaluator{Options: gopush.DefaultOptions}
+	programs := []gopush.Code{mustParse(t, "INTEGER.DUP INTEGER.+")}
+
+	if _, err := evaluator.Evaluate(ctx, programs, doublingCases); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
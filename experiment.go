@@ -0,0 +1,622 @@
+package gopush
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Experiment describes a genetic programming run that evolves Push programs
+// against a dataset.
+type Experiment struct {
+	// The Options of the Interpreters used to evaluate and vary the
+	// programs. Options.RandomSeed seeds the whole run.
+	Options Options
+
+	// The number of programs in every generation
+	PopulationSize int
+
+	// The number of generations after which the run ends
+	MaxGenerations int
+
+	// The parent selection method: lexicase, epsilon-lexicase,
+	// downsampled-lexicase or tournament
+	Selection string
+
+	// The tournament size used by tournament selection
+	TournamentSize int
+
+	// The fraction of fitness cases used per generation by down-sampled
+	// lexicase selection
+	DownsampleRate float64
+
+	// The relative weights with which the variation operators are chosen
+	// to produce a child. The operators are subtree-crossover,
+	// subtree-mutation, point-mutation, uniform-addition,
+	// uniform-deletion, constant-perturbation and reproduction.
+	Operators map[string]float64
+
+	// The per-atom rate used by point-mutation, uniform-addition,
+	// uniform-deletion and constant-perturbation
+	MutationRate float64
+
+	// The standard deviation of the noise added by constant-perturbation
+	PerturbationStdDev float64
+
+	// The run ends early once a program has a total error of at most
+	// ErrorThreshold.
+	ErrorThreshold float64
+
+	// The number of programs evaluated in parallel, see Evaluator
+	Workers int
+
+	// The dataset file and how its columns map onto stacks
+	Dataset string
+	Columns []Column
+
+	// The error assigned to missing outputs, see Dataset
+	MissingOutputPenalty float64
+
+	// Cache, if set, is used by Evolve to avoid evaluating programs again,
+	// see Evaluator. It is not part of the experiment file.
+	Cache *EvaluationCache
+}
+
+// experimentOperators lists the variation operators and the number of parents
+// they need.
+var experimentOperators = map[string]int{
+	"constant-perturbation": 1,
+	"point-mutation":        1,
+	"reproduction":          1,
+	"subtree-crossover":     2,
+	"subtree-mutation":      1,
+	"uniform-addition":      1,
+	"uniform-deletion":      1,
+}
+
+// ParseExperiment parses an experiment description. The format is the one
+// read by ParseOptions, extended with the following parameters:
+//
+//	population-size N
+//	max-generations N
+//	selection lexicase|epsilon-lexicase|downsampled-lexicase|tournament
+//	tournament-size N
+//	downsample-rate R
+//	operator NAME WEIGHT
+//	mutation-rate R
+//	perturbation-stddev S
+//	error-threshold E
+//	workers N
+//	dataset FILE
+//	input COLUMN STACK
+//	output COLUMN STACK [absolute|squared|levenshtein]
+//	missing-output-penalty P
+//
+// If no operator is given, subtree-crossover, subtree-mutation and
+// reproduction are used with weights 0.7, 0.2 and 0.1. If neither types nor
+// instructions are given, those of DefaultOptions are used. Included files
+// may only contain interpreter options.
+func ParseExperiment(s string) (Experiment, error) {
+	return parseExperiment(s, "")
+}
+
+// parseExperiment parses an experiment description, resolving relative
+// include paths against dir.
+func parseExperiment(s, dir string) (Experiment, error) {
+	e := Experiment{
+		Options:              newOptions(),
+		PopulationSize:       100,
+		MaxGenerations:       100,
+		Selection:            "lexicase",
+		TournamentSize:       7,
+		DownsampleRate:       0.25,
+		MutationRate:         0.1,
+		PerturbationStdDev:   1.0,
+		ErrorThreshold:       0,
+		MissingOutputPenalty: defaultMissingPenalty,
+	}
+
+	var parameter, setting string
+	var args []string
+
+	for len(s) > 0 {
+		parameter, setting, s = getParameterSettingPair(s)
+
+		if parameter == "" {
+			break
+		}
+
+		if setting == "" {
+			return Experiment{}, fmt.Errorf("expected setting to follow %q", parameter)
+		}
+
+		args, s = getLineTokens(s)
+
+		if strings.ToLower(parameter) == "include" && len(args) == 0 {
+			if err := e.Options.include(setting, dir, make(map[string]bool)); err != nil {
+				return Experiment{}, fmt.Errorf("include %v: %v", setting, err)
+			}
+			continue
+		}
+
+		if err := e.set(strings.ToLower(parameter), setting, args); err != nil {
+			return Experiment{}, err
+		}
+	}
+
+	if err := e.Options.check(); err != nil {
+		return Experiment{}, err
+	}
+
+	// Use the default types and instructions unless any were given
+	if len(e.Options.AllowedTypes) == 0 && len(e.Options.AllowedInstructions) == 0 {
+		defaults, _ := ParseOptions(defaultConfigFile)
+		e.Options.AllowedTypes = defaults.AllowedTypes
+		e.Options.AllowedInstructions = defaults.AllowedInstructions
+	}
+
+	if e.Operators == nil {
+		e.Operators = map[string]float64{
+			"subtree-crossover": 0.7,
+			"subtree-mutation":  0.2,
+			"reproduction":      0.1,
+		}
+	}
+
+	if err := e.check(); err != nil {
+		return Experiment{}, err
+	}
+
+	return e, nil
+}
+
+// check verifies that the experiment can be run.
+func (e Experiment) check() error {
+	if e.Dataset == "" {
+		return fmt.Errorf("missing dataset")
+	}
+
+	outputs := 0
+	for _, col := range e.Columns {
+		if _, ok := e.Options.AllowedTypes[col.Stack]; !ok && col.Stack != "exec" && col.Stack != "name" {
+			return fmt.Errorf("column %q uses stack %q, which is not an allowed type", col.Name, col.Stack)
+		}
+
+		if col.Output {
+			outputs++
+		}
+	}
+
+	if outputs == 0 {
+		return fmt.Errorf("missing output column")
+	}
+
+	total := 0.0
+	for _, w := range e.Operators {
+		total += w
+	}
+
+	if total <= 0 {
+		return fmt.Errorf("at least one operator must have a weight above 0")
+	}
+
+	return nil
+}
+
+func (e *Experiment) set(parameter, setting string, args []string) error {
+	// Only the parameters with a variable number of arguments may have
+	// more than one
+	switch {
+	case parameter == "operator", parameter == "input", parameter == "output", isListParameter(parameter):
+	default:
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected %q after %s %s", args[0], parameter, setting)
+		}
+	}
+
+	var err error
+
+	switch parameter {
+	case "population-size":
+		e.PopulationSize, err = parsePositiveInt(parameter, setting)
+
+	case "max-generations":
+		e.MaxGenerations, err = strconv.Atoi(setting)
+		if err != nil || e.MaxGenerations < 0 {
+			return fmt.Errorf("could not parse %q as non-negative integer", setting)
+		}
+
+	case "selection":
+		switch setting {
+		case "lexicase", "epsilon-lexicase", "downsampled-lexicase", "tournament":
+			e.Selection = setting
+		default:
+			return fmt.Errorf("unknown selection method %q", setting)
+		}
+
+	case "tournament-size":
+		e.TournamentSize, err = parsePositiveInt(parameter, setting)
+
+	case "downsample-rate":
+		e.DownsampleRate, err = parseRate(parameter, setting)
+
+	case "mutation-rate":
+		e.MutationRate, err = parseRate(parameter, setting)
+
+	case "perturbation-stddev":
+		e.PerturbationStdDev, err = strconv.ParseFloat(setting, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as float", setting)
+		}
+
+	case "error-threshold":
+		e.ErrorThreshold, err = strconv.ParseFloat(setting, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as float", setting)
+		}
+
+	case "missing-output-penalty":
+		e.MissingOutputPenalty, err = strconv.ParseFloat(setting, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as float", setting)
+		}
+
+	case "workers":
+		e.Workers, err = parsePositiveInt(parameter, setting)
+
+	case "dataset":
+		e.Dataset = setting
+
+	case "operator":
+		if _, ok := experimentOperators[setting]; !ok {
+			return fmt.Errorf("unknown operator %q", setting)
+		}
+
+		if len(args) != 1 {
+			return fmt.Errorf("expected weight to follow operator %s", setting)
+		}
+
+		w, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || w < 0 {
+			return fmt.Errorf("could not parse %q as non-negative float", args[0])
+		}
+
+		if e.Operators == nil {
+			e.Operators = make(map[string]float64)
+		}
+		e.Operators[setting] = w
+
+	case "input", "output":
+		if len(args) < 1 || (parameter == "input" && len(args) > 1) || len(args) > 2 {
+			return fmt.Errorf("expected stack to follow %s %s", parameter, setting)
+		}
+
+		col := Column{Name: setting, Stack: strings.ToLower(args[0]), Output: parameter == "output"}
+
+		if len(args) == 2 {
+			switch args[1] {
+			case "absolute":
+				col.Metric = AbsoluteError
+			case "squared":
+				col.Metric = SquaredError
+			case "levenshtein":
+				col.Metric = LevenshteinError
+			default:
+				return fmt.Errorf("unknown error metric %q", args[1])
+			}
+		}
+
+		e.Columns = append(e.Columns, col)
+
+	case "instruction-weight", "erc-integer-set", "erc-float-set":
+		return e.Options.setList(parameter, setting, args)
+
+	default:
+		return e.Options.set(parameter, setting)
+	}
+
+	return err
+}
+
+func parsePositiveInt(parameter, setting string) (int, error) {
+	i, err := strconv.Atoi(setting)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse %q as integer", setting)
+	}
+
+	if i < 1 {
+		return 0, fmt.Errorf("%s must be at least 1, got %v", strings.ToUpper(parameter), i)
+	}
+
+	return i, nil
+}
+
+func parseRate(parameter, setting string) (float64, error) {
+	f, err := strconv.ParseFloat(setting, 64)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse %q as float", setting)
+	}
+
+	if f < 0 || f > 1 {
+		return 0, fmt.Errorf("%s must be between 0 and 1 inclusive, got %v", strings.ToUpper(parameter), f)
+	}
+
+	return f, nil
+}
+
+// ReadExperimentFromFile reads the given experiment description. Relative
+// dataset and include paths are interpreted relative to the directory of the
+// file.
+func ReadExperimentFromFile(filename string) (Experiment, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return Experiment{}, err
+	}
+
+	e, err := parseExperiment(string(b), filepath.Dir(filename))
+	if err != nil {
+		return Experiment{}, err
+	}
+
+	if !filepath.IsAbs(e.Dataset) {
+		e.Dataset = filepath.Join(filepath.Dir(filename), e.Dataset)
+	}
+
+	return e, nil
+}
+
+// LoadDataset reads the dataset of the experiment and checks that the
+// interpreter has the stacks of its columns.
+func (e *Experiment) LoadDataset() (*Dataset, error) {
+	d, err := ReadDatasetFromFile(e.Dataset, e.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.Validate(NewInterpreter(e.Options)); err != nil {
+		return nil, err
+	}
+
+	d.MissingPenalty = e.MissingOutputPenalty
+
+	return d, nil
+}
+
+// Population is a generation of programs together with their error vectors.
+// Errors is nil until the population has been evaluated.
+type Population struct {
+	Generation int
+	Programs   []Code
+	Errors     [][]float64
+}
+
+// GenerationStats summarizes an evaluated generation.
+type GenerationStats struct {
+	Generation       int
+	BestTotalError   float64
+	MeanTotalError   float64
+	MedianTotalError float64
+	BestProgram      Code
+	BestSize         int
+	MeanSize         float64
+
+	// The fraction of distinct programs in the population
+	Diversity float64
+}
+
+// Stats computes the statistics of an evaluated population. The size of a
+// program is its number of points.
+func (p *Population) Stats() GenerationStats {
+	stats := GenerationStats{Generation: p.Generation, BestTotalError: math.Inf(1)}
+
+	if len(p.Programs) == 0 {
+		return stats
+	}
+
+	cases := allCases(p.Errors)
+	totals := make([]float64, len(p.Programs))
+	distinct := make(map[uint64]struct{})
+
+	for j, program := range p.Programs {
+		totals[j] = totalError(p.Errors[j], cases)
+		stats.MeanTotalError += totals[j] / float64(len(p.Programs))
+		stats.MeanSize += float64(program.Points()) / float64(len(p.Programs))
+		distinct[program.Hash()] = struct{}{}
+
+		if j == 0 || totals[j] < stats.BestTotalError {
+			stats.BestTotalError = totals[j]
+			stats.BestProgram = program
+			stats.BestSize = program.Points()
+		}
+	}
+
+	stats.MedianTotalError = median(totals)
+	stats.Diversity = float64(len(distinct)) / float64(len(p.Programs))
+
+	return stats
+}
+
+// Evolve runs the experiment on the given fitness cases, starting from the
+// given population, or from a random one if population is nil. After every
+// generation has been evaluated, report is called with the population and its
+// statistics; if it returns an error, Evolve stops and returns that error. The
+// run ends after MaxGenerations generations, or once a program reaches the
+// ErrorThreshold. Evolve returns the last population.
+//
+// If Options.RandomSeed is 0, it is set to a random seed first. Every
+// generation is bred using a seed derived from it, so a run that is continued
+// from a checkpoint produces the same results as an uninterrupted one.
+func (e *Experiment) Evolve(ctx context.Context, cases []FitnessCase, population *Population, report func(*Population, GenerationStats) error) (*Population, error) {
+	if e.Options.RandomSeed == 0 {
+		e.Options.RandomSeed = rand.Int63()
+	}
+
+	interpreter := NewInterpreter(e.Options)
+	evaluator := Evaluator{Options: e.Options, Workers: e.Workers, Cache: e.Cache}
+
+	if population == nil {
+		interpreter.Reset(generationSeed(e.Options.RandomSeed, 0))
+
+		population = &Population{Programs: make([]Code, e.PopulationSize)}
+		for j := range population.Programs {
+			population.Programs[j] = interpreter.RandomCode(e.Options.MaxPointsInRandomExpression)
+		}
+	}
+
+	for {
+		if population.Errors == nil {
+			errors, err := evaluator.Evaluate(ctx, population.Programs, cases)
+			if err != nil {
+				return population, err
+			}
+			population.Errors = errors
+
+			if err := report(population, population.Stats()); err != nil {
+				return population, err
+			}
+		}
+
+		if population.Generation >= e.MaxGenerations || population.Stats().BestTotalError <= e.ErrorThreshold {
+			return population, nil
+		}
+
+		interpreter.Reset(generationSeed(e.Options.RandomSeed, population.Generation+1))
+		population = e.breed(interpreter, population)
+	}
+}
+
+// generationSeed derives the seed used to breed the given generation.
+func generationSeed(seed int64, generation int) int64 {
+	return seed + int64(generation)*0x5DEECE66D
+}
+
+// breed produces the next generation from the evaluated population.
+func (e *Experiment) breed(i *Interpreter, p *Population) *Population {
+	n := e.PopulationSize
+
+	var parents []int
+	switch e.Selection {
+	case "epsilon-lexicase":
+		parents = EpsilonLexicaseSelection(p.Errors, 2*n, i.Rand)
+	case "downsampled-lexicase":
+		parents = DownsampledLexicaseSelection(p.Errors, 2*n, e.DownsampleRate, i.Rand)
+	case "tournament":
+		parents = TournamentSelection(p.Errors, 2*n, e.TournamentSize, i.Rand)
+	default:
+		parents = LexicaseSelection(p.Errors, 2*n, i.Rand)
+	}
+
+	// Sort the operators so that runs are repeatable
+	operators := make([]string, 0, len(e.Operators))
+	total := 0.0
+	for op, w := range e.Operators {
+		operators = append(operators, op)
+		total += w
+	}
+	sort.Strings(operators)
+
+	next := &Population{Generation: p.Generation + 1, Programs: make([]Code, n)}
+
+	for j := range next.Programs {
+		p1 := p.Programs[parents[2*j]]
+		p2 := p.Programs[parents[2*j+1]]
+
+		op := operators[len(operators)-1]
+		r := i.Rand.Float64() * total
+		for _, candidate := range operators {
+			if r < e.Operators[candidate] {
+				op = candidate
+				break
+			}
+			r -= e.Operators[candidate]
+		}
+
+		switch op {
+		case "constant-perturbation":
+			next.Programs[j] = i.PerturbConstants(p1, e.MutationRate, e.PerturbationStdDev)
+		case "point-mutation":
+			next.Programs[j] = i.PointMutation(p1, e.MutationRate)
+		case "subtree-crossover":
+			next.Programs[j] = i.SubtreeCrossover(p1, p2)
+		case "subtree-mutation":
+			next.Programs[j] = i.SubtreeMutation(p1)
+		case "uniform-addition":
+			next.Programs[j] = i.UniformAddition(p1, e.MutationRate)
+		case "uniform-deletion":
+			next.Programs[j] = i.UniformDeletion(p1, e.MutationRate)
+		default:
+			next.Programs[j] = p1
+		}
+	}
+
+	return next
+}
+
+// checkpoint is the serialized form of a Population.
+type checkpoint struct {
+	RandomSeed int64       `json:"random-seed"`
+	Generation int         `json:"generation"`
+	Programs   []string    `json:"programs"`
+	Errors     [][]float64 `json:"errors"`
+}
+
+// WriteCheckpoint writes the evaluated population and the random seed of the
+// experiment to w as JSON. Infinite and NaN errors are stored as the largest
+// float64.
+func (e *Experiment) WriteCheckpoint(w io.Writer, p *Population) error {
+	cp := checkpoint{
+		RandomSeed: e.Options.RandomSeed,
+		Generation: p.Generation,
+		Programs:   make([]string, len(p.Programs)),
+		Errors:     make([][]float64, len(p.Errors)),
+	}
+
+	for j, program := range p.Programs {
+		cp.Programs[j] = program.String()
+	}
+
+	for j, ev := range p.Errors {
+		cp.Errors[j] = make([]float64, len(ev))
+		for c, err := range ev {
+			if math.IsInf(err, 0) || math.IsNaN(err) {
+				err = math.MaxFloat64
+			}
+			cp.Errors[j][c] = err
+		}
+	}
+
+	return json.NewEncoder(w).Encode(cp)
+}
+
+// ReadCheckpoint reads a population written by WriteCheckpoint and sets the
+// random seed of the experiment to the one stored in the checkpoint.
+func (e *Experiment) ReadCheckpoint(r io.Reader) (*Population, error) {
+	var cp checkpoint
+	if err := json.NewDecoder(r).Decode(&cp); err != nil {
+		return nil, err
+	}
+
+	p := &Population{
+		Generation: cp.Generation,
+		Programs:   make([]Code, len(cp.Programs)),
+		Errors:     cp.Errors,
+	}
+
+	for j, program := range cp.Programs {
+		if err := p.Programs[j].UnmarshalText([]byte(program)); err != nil {
+			return nil, fmt.Errorf("program %v: %v", j, err)
+		}
+	}
+
+	e.Options.RandomSeed = cp.RandomSeed
+
+	return p, nil
+}
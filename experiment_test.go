@@ -0,0 +1,118 @@
+package gopush_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+const testExperiment = `
+dataset            data.csv
+input  x           integer
+output y           integer squared
+population-size    20
+max-generations    3
+selection          tournament
+operator subtree-mutation 1
+operator reproduction     1
+random-seed        1138
+max-points-in-program 30
+error-threshold    -1
+`
+
+func TestParseExperiment(t *testing.T) {
+	e, err := gopush.ParseExperiment(testExperiment)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if e.PopulationSize != 20 || e.MaxGenerations != 3 || e.Selection != "tournament" {
+		t.Errorf("unexpected experiment parameters: %+v", e)
+	}
+
+	if !reflect.DeepEqual(e.Operators, map[string]float64{"subtree-mutation": 1, "reproduction": 1}) {
+		t.Errorf("unexpected operators: %v", e.Operators)
+	}
+
+	expectedColumns := []gopush.Column{
+		{Name: "x", Stack: "integer"},
+		{Name: "y", Stack: "integer", Output: true, Metric: gopush.SquaredError},
+	}
+
+	if !reflect.DeepEqual(e.Columns, expectedColumns) {
+		t.Errorf("expected columns %v, got %v", expectedColumns, e.Columns)
+	}
+
+	if e.Options.RandomSeed != 1138 || e.Options.MaxPointsInProgram != 30 {
+		t.Errorf("interpreter options were not set: %+v", e.Options)
+	}
+
+	errorTests := []string{
+		"input x integer",
+		"dataset d.csv\ninput x integer",
+		"dataset d.csv\noutput y integer\nselection roulette",
+		"dataset d.csv\noutput y integer\noperator crossover 1",
+		"dataset d.csv\noutput y integer\noperator subtree-mutation",
+		"dataset d.csv\noutput y integer\noperator subtree-mutation 0\noperator reproduction 0",
+		"dataset d.csv\noutput y integer cubed",
+		"dataset d.csv\noutput y integer\npopulation-size 0",
+		"dataset d.csv\noutput y integer\nmutation-rate 2",
+		"dataset d.csv\noutput y integer\npopulation-size 10 20",
+	}
+
+	for _, s := range errorTests {
+		if _, err := gopush.ParseExperiment(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestEvolveResumesFromCheckpoint(t *testing.T) {
+	e, err := gopush.ParseExperiment(testExperiment)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var full []*gopush.Population
+	final, err := e.Evolve(context.Background(), doublingCases, nil, func(p *gopush.Population, s gopush.GenerationStats) error {
+		full = append(full, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(full) != 4 || final.Generation != 3 {
+		t.Fatalf("expected 4 generations, got %v ending at generation %v", len(full), final.Generation)
+	}
+
+	var buf bytes.Buffer
+	if err := e.WriteCheckpoint(&buf, full[1]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resumed, err := gopush.ParseExperiment(testExperiment)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resumed.Options.RandomSeed = 0
+
+	start, err := resumed.ReadCheckpoint(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resumedFinal, err := resumed.Evolve(context.Background(), doublingCases, start, func(p *gopush.Population, s gopush.GenerationStats) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(resumedFinal.Programs, final.Programs) {
+		t.Error("expected the resumed run to end with the same population as the uninterrupted one")
+	}
+}
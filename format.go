@@ -0,0 +1,163 @@
+package gopush
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Case selects how Format writes the names of instructions and the boolean
+// literals TRUE and FALSE.
+type Case int
+
+const (
+	// PreserveCase writes instructions as they are.
+	PreserveCase Case = iota
+
+	// UpperCase writes instructions in upper case, e.g. INTEGER.+.
+	UpperCase
+
+	// LowerCase writes instructions in lower case, e.g. integer.+.
+	LowerCase
+)
+
+// FormatOptions control how Code is pretty-printed.
+type FormatOptions struct {
+	// Indent is the number of spaces a list is indented by relative to
+	// its parent
+	Indent int
+
+	// Width is the maximum line width. Lists that fit into the remaining
+	// width are written on a single line. Atoms are never split, so lines
+	// with long atoms can exceed the width. A width of 0 or less writes
+	// everything on a single line.
+	Width int
+
+	// Case selects the case of instructions and boolean literals. Names
+	// and other literals are left as they are. Literals of custom stacks
+	// that contain a dot look like instructions, so programs with such
+	// literals should be formatted with PreserveCase.
+	Case Case
+}
+
+// DefaultFormatOptions are the options used by gopush fmt.
+var DefaultFormatOptions = FormatOptions{
+	Indent: 2,
+	Width:  80,
+	Case:   UpperCase,
+}
+
+// Format returns c pretty-printed according to the options. Lists that do
+// not fit on the current line are broken up: the items are written on the
+// following lines, indented by one level and filled up to the line width, and
+// the closing parenthesis is written on a line of its own. The result parses
+// back into c, see ParseCode.
+func (c Code) Format(o FormatOptions) string {
+	var lines []string
+	o.write(&lines, c, 0)
+	return strings.Join(lines, "\n")
+}
+
+// FormatProgram is like Format, but writes the items of c without the
+// enclosing parentheses, like they appear in a program file. The result ends
+// in a newline and parses back into c, see ParseCode.
+func (c Code) FormatProgram(o FormatOptions) string {
+	if c.Literal != "" {
+		return o.atom(c.Literal) + "\n"
+	}
+
+	var lines []string
+	o.fill(&lines, c.List, 0)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// write appends c to lines, starting a new line at the given depth.
+func (o FormatOptions) write(lines *[]string, c Code, depth int) {
+	indent := strings.Repeat(" ", depth*o.Indent)
+
+	if flat := o.flat(c); o.fits(indent + flat) {
+		*lines = append(*lines, indent+flat)
+		return
+	}
+
+	*lines = append(*lines, indent+"(")
+	o.fill(lines, c.List, depth+1)
+	*lines = append(*lines, indent+")")
+}
+
+// fill appends the items to lines at the given depth, putting as many items
+// on each line as fit. Items that do not fit on a line of their own are
+// broken up by write.
+func (o FormatOptions) fill(lines *[]string, items []Code, depth int) {
+	indent := strings.Repeat(" ", depth*o.Indent)
+	line := ""
+
+	for _, item := range items {
+		flat := o.flat(item)
+
+		if line != "" && o.fits(line+" "+flat) {
+			line += " " + flat
+			continue
+		}
+
+		if line != "" {
+			*lines = append(*lines, line)
+			line = ""
+		}
+
+		if item.Literal != "" || o.fits(indent+flat) {
+			line = indent + flat
+			continue
+		}
+
+		o.write(lines, item, depth)
+	}
+
+	if line != "" {
+		*lines = append(*lines, line)
+	}
+}
+
+// fits returns whether the line fits into the line width.
+func (o FormatOptions) fits(line string) bool {
+	return o.Width <= 0 || len([]rune(line)) <= o.Width
+}
+
+// flat returns c written on a single line.
+func (o FormatOptions) flat(c Code) string {
+	if c.Literal != "" {
+		return o.atom(c.Literal)
+	}
+
+	s := "("
+	for _, sub := range c.List {
+		s += " " + o.flat(sub)
+	}
+	return s + " )"
+}
+
+// atom returns the literal in the case selected by the options.
+func (o FormatOptions) atom(literal string) string {
+	if o.Case == PreserveCase || !isCaseInsensitive(literal) {
+		return literal
+	}
+
+	if o.Case == LowerCase {
+		return strings.ToLower(literal)
+	}
+
+	return strings.ToUpper(literal)
+}
+
+// isCaseInsensitive returns whether the literal is an instruction or a
+// boolean literal, whose case does not matter to the interpreter.
+func isCaseInsensitive(literal string) bool {
+	if _, err := strconv.ParseFloat(literal, 64); err == nil {
+		return false
+	}
+
+	if _, err := strconv.ParseBool(literal); err == nil {
+		return true
+	}
+
+	return strings.Contains(literal, ".")
+}
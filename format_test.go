@@ -0,0 +1,101 @@
+package gopush_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+var formatTests = []struct {
+	program  string
+	options  gopush.FormatOptions
+	expected string
+}{
+	{"1 2 integer.+", gopush.FormatOptions{}, "1 2 integer.+\n"},
+	{"1 2 integer.+", gopush.DefaultFormatOptions, "1 2 INTEGER.+\n"},
+	{"TRUE Foo INTEGER.+ 1E3", gopush.FormatOptions{Case: gopush.LowerCase}, "true Foo integer.+ 1E3\n"},
+	{"( ) (())", gopush.DefaultFormatOptions, "( ) ( ( ) )\n"},
+	{
+		"CODE.QUOTE ( INTEGER.DUP INTEGER.* ( 1 2 3 ) ) CODE.DO",
+		gopush.FormatOptions{Indent: 2, Width: 20},
+		"CODE.QUOTE\n(\n  INTEGER.DUP\n  INTEGER.*\n  ( 1 2 3 )\n)\nCODE.DO\n",
+	},
+	{
+		"A ( B ( C D E F ) G ) H",
+		gopush.FormatOptions{Indent: 4, Width: 12},
+		"A\n(\n    B\n    (\n        C D\n        E F\n    )\n    G\n)\nH\n",
+	},
+	{"A-VERY-LONG-NAME B", gopush.FormatOptions{Width: 5}, "A-VERY-LONG-NAME\nB\n"},
+}
+
+// Tests that FormatProgram breaks up lists that do not fit on a line
+func TestFormatProgram(t *testing.T) {
+	for _, ft := range formatTests {
+		c, err := gopush.ParseCode(ft.program)
+		if err != nil {
+			t.Fatalf("unexpected error while parsing %q: %v", ft.program, err)
+		}
+
+		if s := c.FormatProgram(ft.options); s != ft.expected {
+			t.Errorf("expected %q formatted with %+v to be\n%s\ngot\n%s", ft.program, ft.options, ft.expected, s)
+		}
+	}
+}
+
+// Tests that Format includes the enclosing parentheses
+func TestFormat(t *testing.T) {
+	c, err := gopush.ParseCode("1 ( 2 3 )")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s := c.Format(gopush.DefaultFormatOptions); s != "( 1 ( 2 3 ) )" {
+		t.Errorf("expected ( 1 ( 2 3 ) ), got %q", s)
+	}
+
+	if s := c.Format(gopush.FormatOptions{Indent: 1, Width: 9}); s != "(\n 1\n ( 2 3 )\n)" {
+		t.Errorf("expected the list to be broken up, got %q", s)
+	}
+}
+
+// Tests that the formatted programs of the test suite parse back into the
+// same code
+func TestFormatRoundTrip(t *testing.T) {
+	var files []string
+	for _, suite := range findTestSuites("tests", t) {
+		for _, name := range []string{"1-setup.push", "2-program.push", "3-expected.push"} {
+			if _, err := os.Stat(filepath.Join(suite, name)); err == nil {
+				files = append(files, filepath.Join(suite, name))
+			}
+		}
+	}
+
+	for _, file := range files {
+		program, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("error while reading %q", file)
+		}
+
+		c, err := gopush.ParseCode(string(program))
+		if err != nil {
+			t.Fatalf("unexpected error while parsing %q: %v", file, err)
+		}
+
+		for _, width := range []int{0, 10, 40} {
+			options := gopush.FormatOptions{Indent: 2, Width: width}
+
+			c2, err := gopush.ParseCode(c.FormatProgram(options))
+			if err != nil {
+				t.Errorf("unexpected error while parsing formatted %q: %v", file, err)
+			}
+
+			if !reflect.DeepEqual(c, c2) {
+				t.Errorf("expected %q formatted with width %v to parse into %v, got %v", file, width, c, c2)
+			}
+		}
+	}
+}
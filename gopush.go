@@ -3,12 +3,12 @@ package gopush
 import (
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
-
-	"github.com/cryptix/goremutake"
 )
 
 // Interpreter is a Push interpreter.
@@ -17,13 +17,22 @@ type Interpreter struct {
 	Options Options
 	Rand    *rand.Rand
 
+	// TraceOutput receives the stacks after every step if Options.Tracing
+	// is set. If it is nil, they are written to standard output.
+	TraceOutput io.Writer
+
 	Definitions        map[string]Code
 	listOfDefinitions  []string
 	listOfInstructions []string
+	cumulativeWeights  []float64 // of listOfInstructions, see randomInstructionIndex
+	ercs               map[string]ERC
+	literalStacks      []string
 
 	numEvalPush       int
 	quoteNextName     bool
 	numNamesGenerated uint
+
+	execPoints []execPoint // see pushPoint
 }
 
 // NewInterpreter returns a new Push Interpreter, configured with the provided Options.
@@ -40,6 +49,7 @@ func NewInterpreter(options Options) *Interpreter {
 		Definitions:        make(map[string]Code),
 		listOfDefinitions:  make([]string, 0),
 		listOfInstructions: make([]string, 0),
+		ercs:               make(map[string]ERC),
 		numEvalPush:        0,
 		quoteNextName:      false,
 		numNamesGenerated:  0,
@@ -49,6 +59,7 @@ func NewInterpreter(options Options) *Interpreter {
 	interpreter.RegisterStack("exec", newExecStack(interpreter))
 	interpreter.RegisterStack("name", newNameStack(interpreter))
 	interpreter.listOfInstructions = append(interpreter.listOfInstructions, "NAME-ERC")
+	interpreter.ercs["NAME-ERC"] = nameERC
 
 	if _, ok := options.AllowedTypes["boolean"]; ok {
 		interpreter.RegisterStack("boolean", newBooleanStack(interpreter))
@@ -61,11 +72,13 @@ func NewInterpreter(options Options) *Interpreter {
 	if _, ok := options.AllowedTypes["float"]; ok {
 		interpreter.RegisterStack("float", newFloatStack(interpreter))
 		interpreter.listOfInstructions = append(interpreter.listOfInstructions, "FLOAT-ERC")
+		interpreter.ercs["FLOAT-ERC"] = floatERC
 	}
 
 	if _, ok := options.AllowedTypes["integer"]; ok {
 		interpreter.RegisterStack("integer", newIntStack(interpreter))
 		interpreter.listOfInstructions = append(interpreter.listOfInstructions, "INTEGER-ERC")
+		interpreter.ercs["INTEGER-ERC"] = integerERC
 	}
 
 	return interpreter
@@ -89,6 +102,11 @@ func (i *Interpreter) RegisterStack(name string, s *Stack) {
 		}
 	}
 
+	if s.ParseLiteral != nil {
+		i.literalStacks = append(i.literalStacks, name)
+		sort.Strings(i.literalStacks)
+	}
+
 	// Add the Stack's functions to the list of functions
 	for fn := range s.Functions {
 		i.listOfInstructions = append(i.listOfInstructions, strings.ToUpper(name+"."+fn))
@@ -96,12 +114,85 @@ func (i *Interpreter) RegisterStack(name string, s *Stack) {
 
 	// Sort the instructions (otherwise runs aren't repeatable)
 	sort.Strings(i.listOfInstructions)
+	i.cumulativeWeights = nil
+}
+
+// randomInstructionIndex chooses an index into the list of instructions
+// followed by the list of definitions, according to the instruction weights.
+// Definitions have a weight of 1.
+func (i *Interpreter) randomInstructionIndex() int {
+	total := len(i.listOfInstructions) + len(i.listOfDefinitions)
+
+	// Without weights, choose uniformly, as this consumes the random
+	// number generator the same way as before weights existed
+	if len(i.Options.InstructionWeights) == 0 && i.Options.ercWeight() == 1 {
+		return i.Rand.Intn(total)
+	}
+
+	// The cumulative weights are cached until the list of instructions
+	// changes
+	if i.cumulativeWeights == nil {
+		i.cumulativeWeights = make([]float64, len(i.listOfInstructions))
+		sum := 0.0
+		for n, instr := range i.listOfInstructions {
+			sum += i.instructionWeight(instr)
+			i.cumulativeWeights[n] = sum
+		}
+	}
+
+	instructionSum := 0.0
+	if len(i.cumulativeWeights) > 0 {
+		instructionSum = i.cumulativeWeights[len(i.cumulativeWeights)-1]
+	}
+
+	sum := instructionSum + float64(len(i.listOfDefinitions))
+	if sum <= 0 {
+		return i.Rand.Intn(total)
+	}
+
+	r := i.Rand.Float64() * sum
+
+	if r >= instructionSum && len(i.listOfDefinitions) > 0 {
+		n := len(i.listOfInstructions) + int(r-instructionSum)
+		if n >= total {
+			n = total - 1
+		}
+		return n
+	}
+
+	n := sort.Search(len(i.cumulativeWeights), func(n int) bool {
+		return r < i.cumulativeWeights[n]
+	})
+
+	// Rounding errors can leave r slightly above the last weight
+	if n == len(i.cumulativeWeights) {
+		n--
+		for n > 0 && i.cumulativeWeights[n] == i.cumulativeWeights[n-1] {
+			n--
+		}
+	}
+
+	return n
+}
+
+// instructionWeight returns the weight of the given instruction or ephemeral
+// random constant.
+func (i *Interpreter) instructionWeight(instr string) float64 {
+	if w, ok := i.Options.InstructionWeights[strings.ToLower(instr)]; ok {
+		return w
+	}
+
+	if i.isERC(instr) {
+		return i.Options.ercWeight()
+	}
+
+	return 1
 }
 
 func (i *Interpreter) randomInstruction() Code {
 	var instr string
 
-	n := i.Rand.Intn(len(i.listOfInstructions) + len(i.listOfDefinitions))
+	n := i.randomInstructionIndex()
 
 	if n < len(i.listOfInstructions) {
 		instr = i.listOfInstructions[n]
@@ -109,37 +200,33 @@ func (i *Interpreter) randomInstruction() Code {
 		instr = i.listOfDefinitions[n-len(i.listOfInstructions)]
 	}
 
-	switch instr {
-	case "INTEGER-ERC":
-		// Generate ephemeral random constant integer
-		high := i.Options.MaxRandomInteger
-		low := i.Options.MinRandomInteger
-		instr = fmt.Sprint(i.Rand.Int63n(high+1-low) + low)
-
-	case "FLOAT-ERC":
-		// Generate ephemeral random constant float
-		high := i.Options.MaxRandomFloat
-		low := i.Options.MinRandomFloat
-		instr = fmt.Sprint(i.Rand.Float64()*(high-low) + low)
-		if !strings.Contains(instr, ".") {
-			instr += ".0"
-		}
-
-	case "NAME-ERC":
-		// Generate ephemeral random constant NAME
-		if i.Rand.Float64() < i.Options.NewERCNameProbabilty || i.numNamesGenerated == 0 {
-			// Generate a new random NAME
-			instr = goremutake.Encode(i.numNamesGenerated)
-			i.numNamesGenerated++
-		} else {
-			// Use a random, already generated NAME
-			instr = goremutake.Encode(uint(i.Rand.Intn(int(i.numNamesGenerated))))
-		}
+	if g, ok := i.ercs[instr]; ok {
+		return g.Generate(i)
 	}
 
 	return Code{Length: 1, Literal: instr}
 }
 
+// Reset empties all stacks, removes all definitions and reseeds the random
+// number generator with the given seed. This allows the Interpreter to be
+// reused for another, independent run.
+func (i *Interpreter) Reset(seed int64) {
+	for _, s := range i.Stacks {
+		s.Flush()
+	}
+
+	i.Options.RandomSeed = seed
+	i.Rand.Seed(seed)
+
+	i.Definitions = make(map[string]Code)
+	i.listOfDefinitions = i.listOfDefinitions[:0]
+
+	i.numEvalPush = 0
+	i.quoteNextName = false
+	i.numNamesGenerated = 0
+	i.execPoints = i.execPoints[:0]
+}
+
 // StackOK verifies that the given stack exists and has at least `mindepth`
 // elements on it. This is used in stack functions to check if enough operands
 // are available to carry out an instruction.
@@ -164,19 +251,36 @@ func (i *Interpreter) define(name string, code Code) {
 	i.Definitions[name] = code
 }
 
+// printInterpreterState writes the stacks, in the order of their names, to the
+// TraceOutput.
 func (i *Interpreter) printInterpreterState() {
-	fmt.Println("Step", i.numEvalPush)
-	for k, v := range i.Stacks {
-		fmt.Printf("%s:\n", k)
-		for i := len(v.Stack) - 1; i >= 0; i-- {
-			fmt.Printf("- %v\n", v.Stack[i])
+	w := i.TraceOutput
+	if w == nil {
+		w = os.Stdout
+	}
+
+	names := make([]string, 0, len(i.Stacks))
+	for name := range i.Stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Step", i.numEvalPush)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s:\n", name)
+		s := i.Stacks[name].Stack
+		for j := len(s) - 1; j >= 0; j-- {
+			fmt.Fprintf(w, "- %v\n", s[j])
 		}
 	}
-	fmt.Println()
-	fmt.Println()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w)
 }
 
-func (i *Interpreter) runCode(program Code) (err error) {
+// runCode pushes the program onto the EXEC stack and executes items until the
+// stack is empty. point is the point of the program in the program passed to
+// RunCode, see RuntimeError, or -1 for code that does not come from there.
+func (i *Interpreter) runCode(program Code, point int) (err error) {
 
 	// Recover from a panic that could occur while executing an instruction.
 	// Because it is more convenient for functions to not return an error,
@@ -187,7 +291,7 @@ func (i *Interpreter) runCode(program Code) (err error) {
 		}
 	}()
 
-	i.Stacks["exec"].Push(program)
+	i.pushPoint(program, point)
 
 	for i.Stacks["exec"].Len() > 0 && i.numEvalPush < i.Options.EvalPushLimit {
 
@@ -195,87 +299,287 @@ func (i *Interpreter) runCode(program Code) (err error) {
 			i.printInterpreterState()
 		}
 
-		item := i.Stacks["exec"].Pop().(Code)
-		i.numEvalPush++
+		if err := i.step(); err != nil {
+			return err
+		}
+	}
+
+	if i.numEvalPush >= i.Options.EvalPushLimit {
+		return errors.New("the EvalPushLimit was exceeded")
+	}
 
-		// If the item on top of the exec stack is a list, push it in
-		// reverse order
-		if item.Literal == "" {
-			for j := len(item.List) - 1; j >= 0; j-- {
-				i.Stacks["exec"].Push(item.List[j])
-			}
-			continue
+	return nil
+}
+
+// RuntimeError is the error returned when executing an item of a program
+// fails. It records the item and the step at which it was executed, so that
+// the error can be traced back to the source.
+type RuntimeError struct {
+	Err  error
+	Item Code
+	Step int
+
+	// Point is the point of Item in the program passed to RunCode or Load,
+	// which indexes the SourceMap of the program. It is -1 if the item did
+	// not come from the program as written, e.g. because an instruction or
+	// a Debugger put it onto the EXEC stack. SourceMap.Find can then list
+	// the candidates.
+	Point int
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// step pops the item on top of the exec stack and executes it. Errors, including
+// those raised by instructions through panics, are returned as *RuntimeError.
+func (i *Interpreter) step() (err error) {
+	item, point := i.popPoint()
+	i.numEvalPush++
+
+	defer func() {
+		if perr := recover(); perr != nil {
+			err = perr.(error)
+		}
+
+		// Errors from nested execution, e.g. by CODE.DO, already
+		// point at the innermost item
+		if _, ok := err.(*RuntimeError); err != nil && !ok {
+			err = &RuntimeError{Err: err, Item: item, Step: i.numEvalPush, Point: point}
+		}
+	}()
+
+	return i.execute(item, point)
+}
+
+// execute executes the given item, which came from the given point of the
+// program.
+func (i *Interpreter) execute(item Code, point int) error {
+
+	// If the item on top of the exec stack is a list, push it in
+	// reverse order
+	if item.Literal == "" {
+		i.pushItems(item, point)
+		return nil
+	}
+
+	// Try to parse the item on top of the exec stack as a literal
+	if intlit, err := strconv.ParseInt(item.Literal, 10, 64); err == nil {
+		if !i.StackOK("integer", 0) {
+			return fmt.Errorf("found integer literal %v, but the integer stack is disabled", intlit)
+		}
+		i.Stacks["integer"].Push(intlit)
+		return nil
+	}
+
+	if floatlit, err := strconv.ParseFloat(item.Literal, 64); err == nil {
+		if !i.StackOK("float", 0) {
+			return fmt.Errorf("found float literal %v, but the float stack is disabled", floatlit)
+		}
+		i.Stacks["float"].Push(floatlit)
+		return nil
+	}
+
+	if boollit, err := strconv.ParseBool(item.Literal); err == nil {
+		if !i.StackOK("boolean", 0) {
+			return fmt.Errorf("found boolean literal %v, but the boolean stack is disabled", boollit)
 		}
+		i.Stacks["boolean"].Push(boollit)
+		return nil
+	}
 
-		// Try to parse the item on top of the exec stack as a literal
-		if intlit, err := strconv.ParseInt(item.Literal, 10, 64); err == nil {
-			if !i.StackOK("integer", 0) {
-				return fmt.Errorf("found integer literal %v, but the integer stack is disabled", intlit)
-			}
-			i.Stacks["integer"].Push(intlit)
-			continue
+	for _, name := range i.literalStacks {
+		if v, ok := i.Stacks[name].ParseLiteral(item.Literal); ok {
+			i.Stacks[name].Push(v)
+			return nil
 		}
+	}
+
+	// Try to parse the item on top of the exec stack as instruction
+	if strings.Contains(item.Literal, ".") {
+		stack := strings.ToLower(item.Literal[:strings.Index(item.Literal, ".")])
+		operation := strings.ToLower(item.Literal[strings.Index(item.Literal, ".")+1:])
 
-		if floatlit, err := strconv.ParseFloat(item.Literal, 64); err == nil {
-			if !i.StackOK("float", 0) {
-				return fmt.Errorf("found float literal %v, but the float stack is disabled", floatlit)
-			}
-			i.Stacks["float"].Push(floatlit)
-			continue
+		s, ok := i.Stacks[stack]
+		if !ok {
+			return fmt.Errorf("unknown or disabled stack: %v", stack)
 		}
 
-		if boollit, err := strconv.ParseBool(item.Literal); err == nil {
-			if !i.StackOK("boolean", 0) {
-				return fmt.Errorf("found boolean literal %v, but the boolean stack is disabled", boollit)
-			}
-			i.Stacks["boolean"].Push(boollit)
-			continue
+		f, ok := s.Functions[operation]
+		if !ok {
+			return fmt.Errorf("unknown or disabled instruction %v.%v", stack, operation)
 		}
 
-		// Try to parse the item on top of the exec stack as instruction
-		if strings.Contains(item.Literal, ".") {
-			stack := strings.ToLower(item.Literal[:strings.Index(item.Literal, ".")])
-			operation := strings.ToLower(item.Literal[strings.Index(item.Literal, ".")+1:])
+		f()
+		return nil
+	}
+
+	// If the item is not an instruction, it must be a name, either
+	// bound or unbound. If the quoteNextName flag is false, we can
+	// check if the name is already bound.
+	if !i.quoteNextName {
+		if d, ok := i.Definitions[strings.ToLower(item.Literal)]; ok {
+			// Name is already bound, push its value onto the exec stack
+			i.Stacks["exec"].Push(d)
+			return nil
+		}
+	}
+
+	// The name is not bound yet, so push it onto the name stack
+	i.Stacks["name"].Push(strings.ToLower(item.Literal))
+	i.quoteNextName = false
+
+	return nil
+}
+
+// pushItems pushes the items of the list onto the EXEC stack in reverse order.
+// The items are numbered in depth-first order after the list itself, which is
+// at the given point.
+func (i *Interpreter) pushItems(list Code, point int) {
+	if point < 0 {
+		for j := len(list.List) - 1; j >= 0; j-- {
+			i.Stacks["exec"].Push(list.List[j])
+		}
+		return
+	}
+
+	// The Length of a list counts the points of its items, but not the
+	// list itself
+	next := point + 1 + list.Length
+	for j := len(list.List) - 1; j >= 0; j-- {
+		item := list.List[j]
+		next -= item.Length
+		if item.Literal == "" {
+			next--
+		}
+		i.pushPoint(item, next)
+	}
+}
+
+// execPoint records that the item at the given depth of the EXEC stack came
+// from the given point of the program.
+type execPoint struct {
+	depth int
+	item  Code
+	point int
+}
+
+// pushPoint pushes an item that came from the given point of the program onto
+// the EXEC stack. The point is recorded together with the item and its depth,
+// so that popPoint can tell whether the item is still the same. Items pushed
+// in any other way, e.g. by instructions or the Debugger, have no point.
+func (i *Interpreter) pushPoint(item Code, point int) {
+	exec := i.Stacks["exec"]
+	exec.Push(item)
+
+	if point < 0 {
+		return
+	}
+
+	depth := int(exec.Len())
+	for len(i.execPoints) > 0 && i.execPoints[len(i.execPoints)-1].depth >= depth {
+		i.execPoints = i.execPoints[:len(i.execPoints)-1]
+	}
+
+	i.execPoints = append(i.execPoints, execPoint{depth: depth, item: item, point: point})
+}
+
+// popPoint pops the top item of the EXEC stack and returns it together with the
+// point of the program it came from, or -1 if it did not come from the program
+// or is not the item recorded by pushPoint anymore.
+func (i *Interpreter) popPoint() (Code, int) {
+	exec := i.Stacks["exec"]
+	depth := int(exec.Len())
+	item := exec.Pop().(Code)
+
+	// Drop the records of items that have been removed since
+	for len(i.execPoints) > 0 && i.execPoints[len(i.execPoints)-1].depth > depth {
+		i.execPoints = i.execPoints[:len(i.execPoints)-1]
+	}
+
+	if len(i.execPoints) == 0 || i.execPoints[len(i.execPoints)-1].depth != depth {
+		return item, -1
+	}
+
+	r := i.execPoints[len(i.execPoints)-1]
+	i.execPoints = i.execPoints[:len(i.execPoints)-1]
 
-			s, ok := i.Stacks[stack]
-			if !ok {
-				return fmt.Errorf("unknown or disabled stack: %v", stack)
-			}
+	if !sameItem(r.item, item) {
+		return item, -1
+	}
+
+	return item, r.point
+}
 
-			f, ok := s.Functions[operation]
-			if !ok {
-				return fmt.Errorf("unknown or disabled instruction %v.%v", stack, operation)
-			}
+// sameItem returns whether a and b are the same atom, or share the items of
+// the same list. Unlike Equal, it does not compare lists item by item.
+func sameItem(a, b Code) bool {
+	if a.Literal != b.Literal || a.Length != b.Length || len(a.List) != len(b.List) {
+		return false
+	}
+
+	return len(a.List) == 0 || &a.List[0] == &b.List[0]
+}
+
+// instructionStack returns the lowercase name of the stack the given literal
+// refers to if the interpreter would execute it as an instruction, and the
+// empty string otherwise.
+func (i *Interpreter) instructionStack(literal string) string {
+	if kind, stack := i.classifyAtom(literal); kind == instructionAtom {
+		return stack
+	}
 
-			f()
-			continue
+	return ""
+}
+
+// Load prepares the interpreter for running the given program step by step
+// using Step. The program is pushed onto the EXEC stack (and onto the CODE
+// stack if TopLevelPushCode is set) and the step count is reset.
+func (i *Interpreter) Load(c Code) {
+	if i.Options.TopLevelPushCode {
+		if s, ok := i.Stacks["code"]; ok {
+			s.Push(c)
 		}
+	}
+
+	i.numEvalPush = 0
+	i.execPoints = i.execPoints[:0]
+	i.pushPoint(c, 0)
+}
 
-		// If the item is not an instruction, it must be a name, either
-		// bound or unbound. If the quoteNextName flag is false, we can
-		// check if the name is already bound.
-		if !i.quoteNextName {
-			if d, ok := i.Definitions[strings.ToLower(item.Literal)]; ok {
-				// Name is already bound, push its value onto the exec stack
-				i.Stacks["exec"].Push(d)
-				continue
-			}
+// Step executes the item on top of the EXEC stack. It does nothing if the EXEC
+// stack is empty, and returns an error if the item could not be executed or
+// the EvalPushLimit has been reached.
+func (i *Interpreter) Step() (err error) {
+	defer func() {
+		if perr := recover(); perr != nil {
+			err = perr.(error)
 		}
+	}()
 
-		// The name is not bound yet, so push it onto the name stack
-		i.Stacks["name"].Push(strings.ToLower(item.Literal))
-		i.quoteNextName = false
+	if i.Stacks["exec"].Len() == 0 {
+		return nil
 	}
 
 	if i.numEvalPush >= i.Options.EvalPushLimit {
 		return errors.New("the EvalPushLimit was exceeded")
 	}
 
-	return nil
+	if i.Options.Tracing {
+		i.printInterpreterState()
+	}
+
+	return i.step()
 }
 
 // RunCode runs the given program (given as Code type) until the EvalPushLimit
-// is reached
+// is reached. The steps of earlier calls count towards the limit until the
+// Interpreter is Reset.
 func (i *Interpreter) RunCode(c Code) error {
 	if i.Options.TopLevelPushCode {
 		if s, ok := i.Stacks["code"]; ok {
@@ -283,7 +587,8 @@ func (i *Interpreter) RunCode(c Code) error {
 		}
 	}
 
-	err := i.runCode(c)
+	i.execPoints = i.execPoints[:0]
+	err := i.runCode(c, 0)
 
 	if i.Options.TopLevelPopCode {
 		if s, ok := i.Stacks["code"]; ok {
@@ -52,6 +52,9 @@ var codeLengthTests = []struct {
 	{"( A )", 2},
 	{"A ( B C )", 4},
 	{"( A ( B ( C D ) E ) F )", 9},
+	{"()", 1},
+	{"(())", 2},
+	{"(A(B(C D)E)F)", 9},
 }
 
 // Tests that ParseCode returns the correct code lengths
@@ -68,6 +71,41 @@ func TestCodeLengths(t *testing.T) {
 	}
 }
 
+var compactCodeTests = []struct {
+	compact string
+	spaced  string
+}{
+	{"(1 2 INTEGER.+)", "( 1 2 INTEGER.+ )"},
+	{"((1)(2))", "( ( 1 ) ( 2 ) )"},
+	{"(CODE.QUOTE(INTEGER.DUP INTEGER.*)CODE.DO)", "( CODE.QUOTE ( INTEGER.DUP INTEGER.* ) CODE.DO )"},
+	{"(EXEC.IF(TRUE)\n\t(FALSE))", "( EXEC.IF ( TRUE ) ( FALSE ) )"},
+}
+
+// Tests that parentheses do not need to be surrounded by whitespace
+func TestParseCompactCode(t *testing.T) {
+	for _, cct := range compactCodeTests {
+		compact, err := gopush.ParseCode(cct.compact)
+		if err != nil {
+			t.Errorf("unexpected error while parsing program %q: %v", cct.compact, err)
+		}
+
+		spaced, err := gopush.ParseCode(cct.spaced)
+		if err != nil {
+			t.Errorf("unexpected error while parsing program %q: %v", cct.spaced, err)
+		}
+
+		if !reflect.DeepEqual(compact, spaced) {
+			t.Errorf("expected %q to parse like %q, got %v", cct.compact, cct.spaced, compact)
+		}
+	}
+
+	for _, program := range []string{"(", "(A (B)", "((A)", "A)B"} {
+		if _, err := gopush.ParseCode(program); err == nil {
+			t.Errorf("expected an error while parsing %q", program)
+		}
+	}
+}
+
 // Helper function to find test suites
 func findTestSuites(directory string, t *testing.T) (testsuites []string) {
 	filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
@@ -186,3 +224,334 @@ func TestSuite(t *testing.T) {
 		}
 	}
 }
+
+// Tests that Step executes a loaded program one item at a time
+func TestStepping(t *testing.T) {
+	interpreter := gopush.NewInterpreter(gopush.DefaultOptions)
+
+	c, err := gopush.ParseCode("1 2 INTEGER.+")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	interpreter.Load(c)
+
+	for step := 1; interpreter.Stacks["exec"].Len() > 0; step++ {
+		if err := interpreter.Step(); err != nil {
+			t.Fatalf("unexpected error in step %v: %v", step, err)
+		}
+
+		if step == 3 && interpreter.Stacks["integer"].Len() != 2 {
+			t.Errorf("expected two integers after step 3, got %v", interpreter.Stacks["integer"].Stack)
+		}
+	}
+
+	if interpreter.Stacks["integer"].Peek().(int64) != 3 {
+		t.Errorf("expected integer stack to contain 3, got %v", interpreter.Stacks["integer"].Stack)
+	}
+}
+
+// Tests that the steps of consecutive RunCode calls count towards the same
+// EvalPushLimit, and that Load and Reset start a fresh count
+func TestEvalPushLimitAcrossCalls(t *testing.T) {
+	options := gopush.DefaultOptions
+	options.EvalPushLimit = 10
+
+	interpreter := gopush.NewInterpreter(options)
+
+	// Each run takes 6 steps: the list itself and its five items
+	if err := interpreter.Run("1 2 3 4 5"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := interpreter.Run("1 2 3 4 5"); err == nil {
+		t.Error("expected the EvalPushLimit to be exceeded")
+	}
+
+	interpreter.Reset(1)
+	if err := interpreter.Run("1 2 3 4 5"); err != nil {
+		t.Fatalf("unexpected error after Reset: %v", err)
+	}
+
+	interpreter.Load(mustParse(t, "1 2 3 4 5"))
+	for step := 1; interpreter.Stacks["exec"].Len() > 0; step++ {
+		if err := interpreter.Step(); err != nil {
+			t.Fatalf("unexpected error in step %v after Load: %v", step, err)
+		}
+	}
+}
+
+// Tests that random code generation honors the instruction weights
+func TestInstructionWeightsInRandomCode(t *testing.T) {
+	options, err := gopush.ParseOptions("type integer\ninstruction integer.+\ninstruction integer.-\ninstruction-weight integer.- 0\ninstruction-weight seven-erc 1\ninstruction-weight integer-erc 0\ninstruction-weight name-erc 0\nrandom-seed 1138")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	interpreter := gopush.NewInterpreter(options)
+
+	var check func(c gopush.Code)
+	check = func(c gopush.Code) {
+		if c.Literal != "" && c.Literal != "INTEGER.+" {
+			t.Errorf("expected only INTEGER.+ to be generated, got %v", c.Literal)
+		}
+
+		for _, sub := range c.List {
+			check(sub)
+		}
+	}
+
+	for n := 0; n < 20; n++ {
+		check(interpreter.RandomCode(20))
+	}
+
+	// Registering another generator takes effect despite the weights
+	// computed so far
+	interpreter.RegisterERC("seven", gopush.ERCFunc(func(i *gopush.Interpreter) gopush.Code {
+		return gopush.Code{Length: 1, Literal: "7"}
+	}))
+
+	sevens := 0
+	for n := 0; n < 20; n++ {
+		interpreter.RandomCode(20).Walk(func(_ int, c gopush.Code) bool {
+			if c.Literal == "7" {
+				sevens++
+			} else if c.Literal != "" && c.Literal != "INTEGER.+" {
+				t.Errorf("expected only INTEGER.+ and 7 to be generated, got %v", c.Literal)
+			}
+			return true
+		})
+	}
+
+	if sevens == 0 {
+		t.Error("expected the new generator to be used")
+	}
+}
+
+// Tests that comments are skipped and positions are recorded for every point
+func TestParseCodePositions(t *testing.T) {
+	program := "# squares 3\n3 ( INTEGER.DUP ; duplicate\n  INTEGER.* )\n"
+
+	c, sm, err := gopush.ParseCodePositions(program)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected, _ := gopush.ParseCode("3 ( INTEGER.DUP INTEGER.* )")
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected comments to be skipped, got %v", c)
+	}
+
+	positions := []gopush.Position{{1, 1}, {2, 1}, {2, 3}, {2, 5}, {3, 3}}
+	if !reflect.DeepEqual([]gopush.Position(sm), positions) {
+		t.Errorf("expected positions %v, got %v", positions, sm)
+	}
+
+	_, err = gopush.ParseCode("1\n  ( 2 ( 3 )")
+	if err == nil || err.Error() != "invalid program: 2:3: ( is not closed" {
+		t.Errorf("expected the unclosed parenthesis to be reported, got %v", err)
+	}
+}
+
+// Tests that runtime errors can be traced back to the offending item
+func TestRuntimeErrorPosition(t *testing.T) {
+	options, err := gopush.ParseOptions("type integer\ninstruction integer.+")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c, sm, err := gopush.ParseCodePositions("1 2\n( INTEGER.+ FLOAT.+ )")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	interpreter := gopush.NewInterpreter(options)
+
+	err = interpreter.RunCode(c)
+	rerr, ok := err.(*gopush.RuntimeError)
+	if !ok {
+		t.Fatalf("expected a *RuntimeError, got %#v", err)
+	}
+
+	if rerr.Item.Literal != "FLOAT.+" || rerr.Step != 6 {
+		t.Errorf("expected FLOAT.+ to fail in step 6, got %v in step %v", rerr.Item, rerr.Step)
+	}
+
+	if rerr.Point != 5 || sm[rerr.Point] != (gopush.Position{2, 13}) {
+		t.Errorf("expected the error at point 5 (2:13), got point %v", rerr.Point)
+	}
+
+	positions := sm.Find(c, rerr.Item)
+	if !reflect.DeepEqual(positions, []gopush.Position{{2, 13}}) {
+		t.Errorf("expected the error at 2:13, got %v", positions)
+	}
+}
+
+var runtimeErrorPointTests = []struct {
+	program string
+	point   int
+}{
+	{"FLOAT.+", 1},
+	{"( INTEGER.+ ) ( INTEGER.+ FLOAT.+ ) FLOAT.+", 5},
+	{"( ( 1 ) 2 ) ( ( ) FLOAT.+ )", 7},
+	{"FLOAT.+ FLOAT.+", 1},
+	{"EXEC.DUP FLOAT.+", -1},
+	{"CODE.QUOTE ( FLOAT.+ ) CODE.DO", -1},
+}
+
+// Tests that runtime errors point at the exact item of the program that
+// failed, even if the program contains equal items elsewhere
+func TestRuntimeErrorPoint(t *testing.T) {
+	options, err := gopush.ParseOptions("type integer\ntype code\ninstruction integer.+\ninstruction exec.dup\ninstruction code.quote\ninstruction code.do")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tt := range runtimeErrorPointTests {
+		c, err := gopush.ParseCode(tt.program)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		err = gopush.NewInterpreter(options).RunCode(c)
+		rerr, ok := err.(*gopush.RuntimeError)
+		if !ok {
+			t.Errorf("expected %q to fail with a *RuntimeError, got %#v", tt.program, err)
+			continue
+		}
+
+		if rerr.Point != tt.point {
+			t.Errorf("expected %q to fail at point %v, got %v", tt.program, tt.point, rerr.Point)
+		}
+
+		if sub, ok := c.At(rerr.Point); rerr.Point >= 0 && (!ok || !sub.Equal(rerr.Item)) {
+			t.Errorf("expected point %v of %q to be %v, got %v", rerr.Point, tt.program, rerr.Item, sub)
+		}
+	}
+}
+
+// Tests that items replaced on the EXEC stack do not take over the point of
+// the item they replace
+func TestRuntimeErrorPointModifiedExec(t *testing.T) {
+	options, err := gopush.ParseOptions("type integer\ninstruction integer.+")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	interpreter := gopush.NewInterpreter(options)
+	interpreter.Load(mustParse(t, "INTEGER.+ FLOAT.+"))
+	if err := interpreter.Step(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	exec := interpreter.Stacks["exec"]
+	exec.Stack[len(exec.Stack)-1] = mustParse(t, "FLOAT.-").List[0]
+
+	rerr, ok := interpreter.Step().(*gopush.RuntimeError)
+	if !ok || rerr.Item.Literal != "FLOAT.-" || rerr.Point != -1 {
+		t.Errorf("expected FLOAT.- to fail without a point, got %#v", rerr)
+	}
+}
+
+var parseCodeErrorTests = []struct {
+	program  string
+	problems []gopush.ParseProblem
+}{
+	{"(", []gopush.ParseProblem{{gopush.Position{1, 1}, "( is not closed"}}},
+	{")", []gopush.ParseProblem{{gopush.Position{1, 1}, ") has no matching ("}}},
+	{"A ) B", []gopush.ParseProblem{{gopush.Position{1, 3}, ") has no matching ("}}},
+	{"( A ) )\n( ( B )", []gopush.ParseProblem{
+		{gopush.Position{1, 7}, ") has no matching ("},
+		{gopush.Position{2, 1}, "( is not closed"},
+	}},
+	{"((\n))) ; )", []gopush.ParseProblem{{gopush.Position{2, 3}, ") has no matching ("}}},
+}
+
+// Tests that ParseCode reports all unbalanced parentheses with their positions
+func TestParseCodeErrors(t *testing.T) {
+	for _, pet := range parseCodeErrorTests {
+		_, err := gopush.ParseCode(pet.program)
+
+		perr, ok := err.(*gopush.ParseError)
+		if !ok {
+			t.Errorf("expected a *ParseError while parsing %q, got %v", pet.program, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(perr.Problems, pet.problems) {
+			t.Errorf("expected parsing %q to report %v, got %v", pet.program, pet.problems, perr.Problems)
+		}
+	}
+}
+
+// Tests that strict parsing reports instructions of unknown stacks
+func TestParseCodeStrict(t *testing.T) {
+	interpreter := gopush.NewInterpreter(gopush.DefaultOptions)
+
+	if _, err := interpreter.ParseCodeStrict("( 1.5 INTEGER.+ ) FALSE FOO"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err := interpreter.ParseCodeStrict("1 FOO.BAR (\n  STRING.LENGTH")
+	if err == nil || err.Error() != "invalid program: 1:3: FOO.BAR refers to unknown stack foo; 1:11: ( is not closed; 2:3: STRING.LENGTH refers to unknown stack string" {
+		t.Errorf("expected the unknown stacks to be reported, got %v", err)
+	}
+}
+
+var codeEqualTests = []struct {
+	c1, c2 string
+	equal  bool
+}{
+	{"A", "A", true},
+	{"A", "a", false},
+	{"( A B )", "( A B )", true},
+	{"( A B )", "( B A )", false},
+	{"( A ( B ) )", "( A B )", false},
+	{"( ( ) )", "( ( ) ( ) )", false},
+	{"( )", "( ( ) )", false},
+	{"1 ( 2 ( 3 ) )", "1 ( 2 ( 3 ) )", true},
+}
+
+// Tests that Equal compares the structure of code and that equal code has
+// equal hashes
+func TestCodeEqualAndHash(t *testing.T) {
+	for _, cet := range codeEqualTests {
+		c1, err := gopush.ParseCode(cet.c1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		c2, err := gopush.ParseCode(cet.c2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if c1.Equal(c2) != cet.equal || c2.Equal(c1) != cet.equal {
+			t.Errorf("expected %q and %q to be equal: %v", cet.c1, cet.c2, cet.equal)
+		}
+
+		if (c1.Hash() == c2.Hash()) != cet.equal {
+			t.Errorf("expected %q and %q to have equal hashes: %v", cet.c1, cet.c2, cet.equal)
+		}
+	}
+
+	empty := gopush.Code{List: []gopush.Code{}}
+	if !empty.Equal(gopush.Code{}) || empty.Hash() != (gopush.Code{}).Hash() {
+		t.Error("expected nil and empty lists to be equal")
+	}
+
+	// The hash must not change between releases, since it may be stored
+	c, _ := gopush.ParseCode("1 2 INTEGER.+")
+	if h := c.Hash(); h != 0xfdfad153d16ae622 {
+		t.Errorf("expected the hash of %v to be 0xfdfad153d16ae622, got %#x", c, h)
+	}
+
+	interpreter := gopush.NewInterpreter(gopush.DefaultOptions)
+	if err := interpreter.Run("CODE.QUOTE ( A ) CODE.CDR CODE.QUOTE ( ) CODE.="); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !interpreter.Stacks["boolean"].Peek().(bool) {
+		t.Error("expected CODE.= to treat the empty rest of a list like ( )")
+	}
+}
@@ -1,11 +1,15 @@
 package gopush
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Options holds the configuration options for a Push Interpreter
@@ -63,6 +67,35 @@ type Options struct {
 
 	// AllowedInstructions lists the instructions that are allowed
 	AllowedInstructions map[string]struct{}
+
+	// InstructionWeights holds the relative probabilities with which
+	// instructions are chosen by random code generation. Instructions
+	// without a weight have a weight of 1. The keys are lowercase, e.g.
+	// "integer.+", and may also name one of the ephemeral random constants
+	// INTEGER-ERC, FLOAT-ERC and NAME-ERC.
+	InstructionWeights map[string]float64
+
+	// The weight of each ephemeral random constant that has no weight in
+	// InstructionWeights. If it is 0, e.g. because the Options were not
+	// read from a configuration file, the default of 1 is used. Single
+	// constants are turned off with a weight of 0 in InstructionWeights.
+	ERCWeight float64
+
+	// If not empty, ephemeral random INTEGER constants are chosen from
+	// these values instead of the range given by MinRandomInteger and
+	// MaxRandomInteger.
+	ERCIntegerSet []int64
+
+	// If not empty, ephemeral random FLOAT constants are chosen from these
+	// values instead of the range given by MinRandomFloat and
+	// MaxRandomFloat.
+	ERCFloatSet []float64
+
+	// If ERCFloatStdDev is positive and ERCFloatSet is empty, ephemeral
+	// random FLOAT constants are drawn from a normal distribution with
+	// these parameters.
+	ERCFloatMean   float64
+	ERCFloatStdDev float64
 }
 
 // RegisterStack adds all instructions from the given Stack to the list of
@@ -258,10 +291,49 @@ instruction NAME.YANKDUP
 var DefaultOptions, _ = ParseOptions(defaultConfigFile)
 
 // ParseOptions parses the given string into the Options struct.
+//
+// The name of a builtin instruction always stands for that instruction, so
+// INTEGER.* is the multiplication instruction. Any other name may contain the
+// wildcards * (any sequence of characters) and ? (any single character), in
+// which case all builtin instructions that match are allowed, e.g. INTEGER.?*
+// for all INTEGER instructions or *.DUP for all DUP instructions. Wildcards
+// do not match the instructions of stacks added with RegisterStack, which
+// must be named one by one. A literal * or ? is written as \* or \?. The
+// exclude-instruction parameter disallows the instructions allowed so far
+// that match its name or pattern. It is an error if a pattern matches no
+// instructions.
+//
+// The instruction-weight parameter takes an instruction name or pattern and a
+// weight, e.g. "instruction-weight INTEGER.+ 3", see
+// Options.InstructionWeights. The erc-integer-set and erc-float-set
+// parameters take any number of values on the same line, e.g.
+// "erc-integer-set 0 1 2 10".
+//
+// The include parameter reads the given configuration file as if its contents
+// appeared in place of the include line. Relative paths are interpreted
+// relative to the current directory, or relative to the including file when
+// reading a file with ReadOptionsFromFile.
 func ParseOptions(s string) (Options, error) {
-	o := Options{
+	o := newOptions()
+
+	if err := o.parse(s, "", make(map[string]bool)); err != nil {
+		return Options{}, err
+	}
+
+	if err := o.check(); err != nil {
+		return Options{}, err
+	}
+
+	return o, nil
+}
+
+// newOptions returns Options holding the default parameter settings, but no
+// allowed types or instructions.
+func newOptions() Options {
+	return Options{
 		AllowedInstructions:         make(map[string]struct{}),
 		AllowedTypes:                make(map[string]struct{}),
+		ERCWeight:                   1,
 		EvalPushLimit:               1000,
 		MaxPointsInProgram:          100,
 		MaxPointsInRandomExpression: 25,
@@ -275,7 +347,12 @@ func ParseOptions(s string) (Options, error) {
 		TopLevelPushCode:            true,
 		Tracing:                     false,
 	}
+}
 
+// parse sets the parameters in s. Relative include paths are resolved against
+// dir. including holds the files that are currently being included, to
+// detect include cycles.
+func (o *Options) parse(s, dir string, including map[string]bool) error {
 	var parameter, setting string
 
 	for len(s) > 0 {
@@ -287,156 +364,356 @@ func ParseOptions(s string) (Options, error) {
 		}
 
 		if setting == "" {
-			return Options{}, fmt.Errorf("expected setting to follow %q", parameter)
-		}
-
-		switch strings.ToLower(parameter) {
-		case "type":
-			t := strings.ToLower(setting)
-			switch t {
-			case "boolean":
-				fallthrough
-			case "code":
-				fallthrough
-			case "float":
-				fallthrough
-			case "integer":
-				o.AllowedTypes[t] = struct{}{}
-
-			// NAME and EXEC stacks always exist, so they are a
-			// no-op with the type parameter
-			case "name":
-			case "exec":
-
-			default:
-				return Options{}, fmt.Errorf("unknown type: %q", setting)
+			return fmt.Errorf("expected setting to follow %q", parameter)
+		}
+
+		if strings.ToLower(parameter) == "include" {
+			if err := o.include(setting, dir, including); err != nil {
+				return fmt.Errorf("include %v: %v", setting, err)
 			}
+			continue
+		}
 
-		case "instruction":
-			instr := strings.ToLower(setting)
-			o.AllowedInstructions[instr] = struct{}{}
+		if isListParameter(parameter) {
+			var args []string
+			args, s = getLineTokens(s)
 
-		case "min-random-integer":
-			i, err := strconv.ParseInt(setting, 10, 64)
-			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as integer", setting)
+			if err := o.setList(parameter, setting, args); err != nil {
+				return err
 			}
-			o.MinRandomInteger = i
+			continue
+		}
 
-		case "max-random-integer":
-			i, err := strconv.ParseInt(setting, 10, 64)
-			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as integer", setting)
-			}
-			o.MaxRandomInteger = i
+		if err := o.set(parameter, setting); err != nil {
+			return err
+		}
+	}
 
-		case "min-random-float":
-			f, err := strconv.ParseFloat(setting, 64)
-			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as float", setting)
-			}
-			o.MinRandomFloat = f
+	return nil
+}
+
+// include sets the parameters in the given configuration file.
+func (o *Options) include(filename, dir string, including map[string]bool) error {
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(dir, filename)
+	}
 
-		case "max-random-float":
-			f, err := strconv.ParseFloat(setting, 64)
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	if including[abs] {
+		return fmt.Errorf("include cycle")
+	}
+
+	b, err := ioutil.ReadFile(abs)
+	if err != nil {
+		return err
+	}
+
+	including[abs] = true
+	defer delete(including, abs)
+
+	return o.parse(string(b), filepath.Dir(abs), including)
+}
+
+// isListParameter returns whether the given parameter takes the remaining
+// tokens on its line as additional settings.
+func isListParameter(parameter string) bool {
+	switch strings.ToLower(parameter) {
+	case "instruction-weight", "erc-integer-set", "erc-float-set":
+		return true
+	}
+	return false
+}
+
+// setList sets a parameter that takes several settings.
+func (o *Options) setList(parameter, setting string, args []string) error {
+	switch strings.ToLower(parameter) {
+	case "instruction-weight":
+		return o.setInstructionWeight(setting, args)
+
+	case "erc-integer-set":
+		o.ERCIntegerSet = nil
+		for _, v := range append([]string{setting}, args...) {
+			i, err := strconv.ParseInt(v, 10, 64)
 			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as float", setting)
+				return fmt.Errorf("could not parse %q as integer", v)
 			}
-			o.MaxRandomFloat = f
+			o.ERCIntegerSet = append(o.ERCIntegerSet, i)
+		}
 
-		case "max-points-in-random-expressions":
-			i, err := strconv.ParseInt(setting, 10, 64)
+	case "erc-float-set":
+		o.ERCFloatSet = nil
+		for _, v := range append([]string{setting}, args...) {
+			f, err := strconv.ParseFloat(v, 64)
 			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as integer", setting)
+				return fmt.Errorf("could not parse %q as float", v)
 			}
+			o.ERCFloatSet = append(o.ERCFloatSet, f)
+		}
 
-			if i < 1 {
-				return Options{}, fmt.Errorf("MAX-POINTS-IN-RANDOM-EXPRESSIONS must be at least 1, got %v", i)
-			}
+	default:
+		return fmt.Errorf("unknown parameter %q", parameter)
+	}
 
-			o.MaxPointsInRandomExpression = i
+	return nil
+}
 
-		case "max-points-in-program":
-			i, err := strconv.ParseInt(setting, 10, 64)
-			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as integer", setting)
-			}
+// setInstructionWeight sets the weight of the instructions matching the given
+// pattern, as given by the instruction-weight parameter.
+func (o *Options) setInstructionWeight(pattern string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected weight to follow instruction-weight %v", pattern)
+	}
 
-			if i < 1 {
-				return Options{}, fmt.Errorf("MAX-POINTS-IN-PROGRAM must be at least 1, got %v", i)
-			}
+	w, err := strconv.ParseFloat(args[0], 64)
+	if err != nil {
+		return fmt.Errorf("could not parse %q as float", args[0])
+	}
+
+	return o.weightInstructions(pattern, w)
+}
 
-			o.MaxPointsInProgram = int(i)
+// weightInstructions sets the weight of the instructions matching the given
+// pattern.
+func (o *Options) weightInstructions(pattern string, w float64) error {
+	if w < 0 {
+		return fmt.Errorf("INSTRUCTION-WEIGHT must be at least 0, got %v", w)
+	}
 
-		case "evalpush-limit":
-			i, err := strconv.ParseInt(setting, 10, 64)
-			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as integer", setting)
-			}
+	pattern = strings.ToLower(pattern)
 
-			if i < 1 {
-				return Options{}, fmt.Errorf("EVALPUSH-LIMIT must be at least 1, got %v", i)
-			}
+	names, isPattern := instructionNames(pattern, builtinInstructions())
+	if isPattern && len(names) == 0 {
+		return fmt.Errorf("instruction pattern %q matches no instructions", pattern)
+	}
 
-			o.EvalPushLimit = int(i)
+	if o.InstructionWeights == nil {
+		o.InstructionWeights = make(map[string]float64)
+	}
 
-		case "new-erc-name-probability":
-			f, err := strconv.ParseFloat(setting, 64)
-			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as float", setting)
-			}
+	for _, name := range names {
+		o.InstructionWeights[name] = w
+	}
 
-			if f < 0 || f > 1 {
-				return Options{}, fmt.Errorf("NEW-ERC-NAME-PROBABILITY must be between 0 and 1 inclusive, got %v", f)
-			}
+	return nil
+}
 
-			o.NewERCNameProbabilty = f
+// set sets the given parameter of the configuration file format to the given
+// setting.
+func (o *Options) set(parameter, setting string) error {
+	switch strings.ToLower(parameter) {
+	case "type":
+		t := strings.ToLower(setting)
+		switch t {
+		case "boolean":
+			fallthrough
+		case "code":
+			fallthrough
+		case "float":
+			fallthrough
+		case "integer":
+			o.AllowedTypes[t] = struct{}{}
+
+		// NAME and EXEC stacks always exist, so they are a
+		// no-op with the type parameter
+		case "name":
+		case "exec":
 
-		case "random-seed":
-			i, err := strconv.ParseInt(setting, 10, 64)
-			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as integer", setting)
-			}
+		default:
+			return fmt.Errorf("unknown type: %q", setting)
+		}
 
-			o.RandomSeed = i
+	case "instruction":
+		names, isPattern := instructionNames(strings.ToLower(setting), builtinInstructions())
+		if isPattern && len(names) == 0 {
+			return fmt.Errorf("instruction pattern %q matches no instructions", setting)
+		}
 
-		case "top-level-push-code":
-			b, err := strconv.ParseBool(setting)
-			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as boolean", setting)
-			}
+		for _, instr := range names {
+			o.AllowedInstructions[instr] = struct{}{}
+		}
 
-			o.TopLevelPushCode = b
+	case "erc-weight":
+		f, err := strconv.ParseFloat(setting, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as float", setting)
+		}
 
-		case "top-level-pop-code":
-			b, err := strconv.ParseBool(setting)
-			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as boolean", setting)
-			}
+		if f <= 0 {
+			return fmt.Errorf("ERC-WEIGHT must be above 0, got %v; use INSTRUCTION-WEIGHT to turn off single ERCs", f)
+		}
 
-			o.TopLevelPopCode = b
+		o.ERCWeight = f
 
-		case "tracing":
-			b, err := strconv.ParseBool(setting)
-			if err != nil {
-				return Options{}, fmt.Errorf("could not parse %q as boolean", setting)
+	case "erc-float-mean":
+		f, err := strconv.ParseFloat(setting, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as float", setting)
+		}
+		o.ERCFloatMean = f
+
+	case "erc-float-stddev":
+		f, err := strconv.ParseFloat(setting, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as float", setting)
+		}
+		o.ERCFloatStdDev = f
+
+	case "exclude-instruction":
+		names, _ := instructionNames(strings.ToLower(setting), sortedKeys(o.AllowedInstructions))
+
+		var matches []string
+		for _, instr := range names {
+			if _, ok := o.AllowedInstructions[instr]; ok {
+				matches = append(matches, instr)
 			}
+		}
 
-			o.Tracing = b
-		default:
-			return Options{}, fmt.Errorf("unknown parameter %q", parameter)
+		if len(matches) == 0 {
+			return fmt.Errorf("exclude-instruction %q matches no allowed instructions", setting)
+		}
+
+		for _, instr := range matches {
+			delete(o.AllowedInstructions, instr)
+		}
+
+	case "min-random-integer":
+		i, err := strconv.ParseInt(setting, 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as integer", setting)
+		}
+		o.MinRandomInteger = i
+
+	case "max-random-integer":
+		i, err := strconv.ParseInt(setting, 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as integer", setting)
+		}
+		o.MaxRandomInteger = i
+
+	case "min-random-float":
+		f, err := strconv.ParseFloat(setting, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as float", setting)
+		}
+		o.MinRandomFloat = f
+
+	case "max-random-float":
+		f, err := strconv.ParseFloat(setting, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as float", setting)
+		}
+		o.MaxRandomFloat = f
+
+	case "max-points-in-random-expressions":
+		i, err := strconv.ParseInt(setting, 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as integer", setting)
+		}
+
+		o.MaxPointsInRandomExpression = i
+
+	case "max-points-in-program":
+		i, err := strconv.ParseInt(setting, 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as integer", setting)
+		}
+
+		o.MaxPointsInProgram = int(i)
+
+	case "evalpush-limit":
+		i, err := strconv.ParseInt(setting, 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as integer", setting)
+		}
+
+		o.EvalPushLimit = int(i)
+
+	case "new-erc-name-probability":
+		f, err := strconv.ParseFloat(setting, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as float", setting)
 		}
+
+		o.NewERCNameProbabilty = f
+
+	case "random-seed":
+		i, err := strconv.ParseInt(setting, 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as integer", setting)
+		}
+
+		o.RandomSeed = i
+
+	case "top-level-push-code":
+		b, err := strconv.ParseBool(setting)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as boolean", setting)
+		}
+
+		o.TopLevelPushCode = b
+
+	case "top-level-pop-code":
+		b, err := strconv.ParseBool(setting)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as boolean", setting)
+		}
+
+		o.TopLevelPopCode = b
+
+	case "tracing":
+		b, err := strconv.ParseBool(setting)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as boolean", setting)
+		}
+
+		o.Tracing = b
+	default:
+		return fmt.Errorf("unknown parameter %q", parameter)
+	}
+
+	return nil
+}
+
+// check verifies that the settings are within their ranges and consistent
+// with each other.
+func (o Options) check() error {
+	if o.MaxPointsInRandomExpression < 1 {
+		return fmt.Errorf("MAX-POINTS-IN-RANDOM-EXPRESSIONS must be at least 1, got %v", o.MaxPointsInRandomExpression)
+	}
+
+	if o.MaxPointsInProgram < 1 {
+		return fmt.Errorf("MAX-POINTS-IN-PROGRAM must be at least 1, got %v", o.MaxPointsInProgram)
+	}
+
+	if o.EvalPushLimit < 1 {
+		return fmt.Errorf("EVALPUSH-LIMIT must be at least 1, got %v", o.EvalPushLimit)
+	}
+
+	if o.NewERCNameProbabilty < 0 || o.NewERCNameProbabilty > 1 {
+		return fmt.Errorf("NEW-ERC-NAME-PROBABILITY must be between 0 and 1 inclusive, got %v", o.NewERCNameProbabilty)
+	}
+
+	if o.ERCWeight < 0 {
+		return fmt.Errorf("ERC-WEIGHT must be at least 0, got %v", o.ERCWeight)
+	}
+
+	if o.ERCFloatStdDev < 0 {
+		return fmt.Errorf("ERC-FLOAT-STDDEV must be at least 0, got %v", o.ERCFloatStdDev)
 	}
 
 	if o.MinRandomInteger > o.MaxRandomInteger {
-		return Options{}, fmt.Errorf("MIN-RANDOM-INTEGER (%v) must be less than or equal to MAX-RANDOM-INTEGER (%v)", o.MinRandomInteger, o.MaxRandomInteger)
+		return fmt.Errorf("MIN-RANDOM-INTEGER (%v) must be less than or equal to MAX-RANDOM-INTEGER (%v)", o.MinRandomInteger, o.MaxRandomInteger)
 	}
 
 	if o.MinRandomFloat > o.MaxRandomFloat {
-		return Options{}, fmt.Errorf("MIN-RANDOM-FLOAT (%v) must be less than or equal to MAX-RANDOM-FLOAT (%v)", o.MinRandomFloat, o.MaxRandomFloat)
+		return fmt.Errorf("MIN-RANDOM-FLOAT (%v) must be less than or equal to MAX-RANDOM-FLOAT (%v)", o.MinRandomFloat, o.MaxRandomFloat)
 	}
 
-	return o, nil
+	return nil
 }
 
 // ReadOptions reads a configuration file from the given io.Reader and returns
@@ -458,17 +735,263 @@ func ReadOptions(r io.Reader) (Options, error) {
 }
 
 // ReadOptionsFromFile reads the given configuration file and returns the
-// corresponding Options struct
+// corresponding Options struct. Files ending in .json are read with
+// ReadOptionsJSON, files ending in .yaml or .yml with ReadOptionsYAML and all
+// others with ParseOptions.
 func ReadOptionsFromFile(filename string) (Options, error) {
 	b, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return Options{}, err
 	}
 
-	o, err := ParseOptions(string(b))
-	if err != nil {
+	switch optionsFormat(filename) {
+	case "json":
+		return ReadOptionsJSON(bytes.NewReader(b))
+	case "yaml":
+		return ReadOptionsYAML(bytes.NewReader(b))
+	}
+
+	o := newOptions()
+
+	if err := o.include(filename, "", make(map[string]bool)); err != nil {
+		return Options{}, err
+	}
+
+	if err := o.check(); err != nil {
 		return Options{}, err
 	}
 
 	return o, nil
 }
+
+// WriteTo writes the options to w in the configuration file format read by
+// ParseOptions. The output is canonical: the parameters are written in a
+// fixed order, followed by the sorted types and instructions. Types that
+// ParseOptions does not know, such as those added by RegisterStack, are
+// written as comments, because they must be registered again by the program.
+// NAME and EXEC are omitted, because these stacks always exist.
+func (o Options) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintln(&buf, "## PARAMETER SETTINGS")
+	fmt.Fprintf(&buf, "TOP-LEVEL-PUSH-CODE %v\n", strings.ToUpper(strconv.FormatBool(o.TopLevelPushCode)))
+	fmt.Fprintf(&buf, "TOP-LEVEL-POP-CODE %v\n", strings.ToUpper(strconv.FormatBool(o.TopLevelPopCode)))
+	fmt.Fprintf(&buf, "EVALPUSH-LIMIT %v\n", o.EvalPushLimit)
+	fmt.Fprintf(&buf, "NEW-ERC-NAME-PROBABILITY %v\n", formatOptionFloat(o.NewERCNameProbabilty))
+	fmt.Fprintf(&buf, "MAX-POINTS-IN-PROGRAM %v\n", o.MaxPointsInProgram)
+	fmt.Fprintf(&buf, "MAX-POINTS-IN-RANDOM-EXPRESSIONS %v\n", o.MaxPointsInRandomExpression)
+	fmt.Fprintf(&buf, "MAX-RANDOM-FLOAT %v\n", formatOptionFloat(o.MaxRandomFloat))
+	fmt.Fprintf(&buf, "MIN-RANDOM-FLOAT %v\n", formatOptionFloat(o.MinRandomFloat))
+	fmt.Fprintf(&buf, "MAX-RANDOM-INTEGER %v\n", o.MaxRandomInteger)
+	fmt.Fprintf(&buf, "MIN-RANDOM-INTEGER %v\n", o.MinRandomInteger)
+	fmt.Fprintf(&buf, "TRACING %v\n", strings.ToUpper(strconv.FormatBool(o.Tracing)))
+	fmt.Fprintf(&buf, "RANDOM-SEED %v\n", o.RandomSeed)
+	fmt.Fprintf(&buf, "ERC-WEIGHT %v\n", formatOptionFloat(o.ercWeight()))
+	fmt.Fprintf(&buf, "ERC-FLOAT-MEAN %v\n", formatOptionFloat(o.ERCFloatMean))
+	fmt.Fprintf(&buf, "ERC-FLOAT-STDDEV %v\n", formatOptionFloat(o.ERCFloatStdDev))
+
+	if len(o.ERCIntegerSet) > 0 {
+		fmt.Fprint(&buf, "ERC-INTEGER-SET")
+		for _, v := range o.ERCIntegerSet {
+			fmt.Fprintf(&buf, " %v", v)
+		}
+		fmt.Fprintln(&buf)
+	}
+
+	if len(o.ERCFloatSet) > 0 {
+		fmt.Fprint(&buf, "ERC-FLOAT-SET")
+		for _, v := range o.ERCFloatSet {
+			fmt.Fprintf(&buf, " %v", formatOptionFloat(v))
+		}
+		fmt.Fprintln(&buf)
+	}
+
+	fmt.Fprintln(&buf, "\n## TYPES")
+	for _, t := range sortedKeys(o.AllowedTypes) {
+		switch t {
+		case "boolean", "code", "float", "integer":
+			fmt.Fprintf(&buf, "type %v\n", strings.ToUpper(t))
+		case "name", "exec":
+			// These stacks always exist
+		default:
+			fmt.Fprintf(&buf, "# type %v (must be registered with RegisterStack)\n", strings.ToUpper(t))
+		}
+	}
+
+	fmt.Fprintln(&buf, "\n## INSTRUCTIONS")
+	for _, instr := range sortedKeys(o.AllowedInstructions) {
+		fmt.Fprintf(&buf, "instruction %v\n", formatInstruction(strings.ToUpper(instr)))
+	}
+
+	if len(o.InstructionWeights) > 0 {
+		fmt.Fprintln(&buf, "\n## INSTRUCTION WEIGHTS")
+
+		names := make([]string, 0, len(o.InstructionWeights))
+		for name := range o.InstructionWeights {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(&buf, "instruction-weight %v %v\n", formatInstruction(strings.ToUpper(name)), formatOptionFloat(o.InstructionWeights[name]))
+		}
+	}
+
+	return buf.WriteTo(w)
+}
+
+// String returns the options in the configuration file format, see WriteTo.
+func (o Options) String() string {
+	var buf bytes.Buffer
+	o.WriteTo(&buf)
+	return buf.String()
+}
+
+// ercWeight returns the ERCWeight, or the default of 1 if it is unset.
+func (o Options) ercWeight() float64 {
+	if o.ERCWeight == 0 {
+		return 1
+	}
+	return o.ERCWeight
+}
+
+// formatOptionFloat formats f so that it parses back to the same value.
+func formatOptionFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEnN") {
+		s += ".0"
+	}
+	return s
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// builtinInstructions returns the sorted full names of the builtin
+// instructions, e.g. "integer.+".
+func builtinInstructions() []string {
+	var names []string
+	for t, instructions := range BuiltinInstructions() {
+		for _, instr := range instructions {
+			names = append(names, t+"."+instr)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// instructionNames returns the instructions that s names among the given
+// sorted candidates, and whether s was taken as a pattern. A candidate equal to
+// s is always taken literally, so that the wildcards in the names of builtin
+// instructions such as INTEGER.* and CODE.DO* keep their meaning. Otherwise, s
+// is matched as a pattern if it contains unescaped wildcards, or taken as the
+// name of a single, possibly unknown, instruction.
+func instructionNames(s string, candidates []string) (names []string, isPattern bool) {
+	if j := sort.SearchStrings(candidates, s); j < len(candidates) && candidates[j] == s {
+		return []string{s}, false
+	}
+
+	if !isInstructionPattern(s) {
+		return []string{unescapeInstruction(s)}, false
+	}
+
+	return matchInstructions(s, candidates), true
+}
+
+// isInstructionPattern returns whether s contains an unescaped wildcard.
+func isInstructionPattern(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '*', '?':
+			return true
+		}
+	}
+	return false
+}
+
+// escapeInstruction escapes the wildcards in the instruction name s.
+func escapeInstruction(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`)
+	return r.Replace(s)
+}
+
+// formatInstruction returns the instruction name s as it is written in
+// configuration files. The names of builtin instructions stand for themselves,
+// other names have their wildcards escaped.
+func formatInstruction(s string) string {
+	builtins := builtinInstructions()
+	if j := sort.SearchStrings(builtins, strings.ToLower(s)); j < len(builtins) && builtins[j] == strings.ToLower(s) {
+		return s
+	}
+
+	return escapeInstruction(s)
+}
+
+// unescapeInstruction removes the escapes from s.
+func unescapeInstruction(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// matchInstructions returns the names that match the given pattern.
+func matchInstructions(pattern string, names []string) []string {
+	var matches []string
+	for _, name := range names {
+		if globMatch(pattern, name) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// globMatch returns whether name matches the pattern, where * matches any
+// sequence of characters, ? matches any single character and \ escapes the
+// following character.
+func globMatch(pattern, name string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(name); i >= 0; i-- {
+				if globMatch(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(name) == 0 {
+				return false
+			}
+			_, size := utf8.DecodeRuneInString(name)
+			pattern, name = pattern[1:], name[size:]
+			continue
+
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+		}
+
+		if len(name) == 0 || pattern[0] != name[0] {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
@@ -0,0 +1,261 @@
+package gopush
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// optionsFile is the representation of Options in JSON and YAML files. The
+// keys are the parameter names of the configuration file format.
+type optionsFile struct {
+	TopLevelPushCode            bool               `json:"top-level-push-code" yaml:"top-level-push-code"`
+	TopLevelPopCode             bool               `json:"top-level-pop-code" yaml:"top-level-pop-code"`
+	EvalPushLimit               int                `json:"evalpush-limit" yaml:"evalpush-limit"`
+	NewERCNameProbabilty        float64            `json:"new-erc-name-probability" yaml:"new-erc-name-probability"`
+	MaxPointsInProgram          int                `json:"max-points-in-program" yaml:"max-points-in-program"`
+	MaxPointsInRandomExpression int64              `json:"max-points-in-random-expressions" yaml:"max-points-in-random-expressions"`
+	MaxRandomFloat              float64            `json:"max-random-float" yaml:"max-random-float"`
+	MinRandomFloat              float64            `json:"min-random-float" yaml:"min-random-float"`
+	MaxRandomInteger            int64              `json:"max-random-integer" yaml:"max-random-integer"`
+	MinRandomInteger            int64              `json:"min-random-integer" yaml:"min-random-integer"`
+	Tracing                     bool               `json:"tracing" yaml:"tracing"`
+	RandomSeed                  int64              `json:"random-seed" yaml:"random-seed"`
+	Types                       []string           `json:"types" yaml:"types"`
+	Instructions                []string           `json:"instructions" yaml:"instructions"`
+	ExcludeInstructions         []string           `json:"exclude-instructions,omitempty" yaml:"exclude-instructions,omitempty"`
+	ERCWeight                   float64            `json:"erc-weight" yaml:"erc-weight"`
+	InstructionWeights          map[string]float64 `json:"instruction-weights,omitempty" yaml:"instruction-weights,omitempty"`
+	ERCIntegerSet               []int64            `json:"erc-integer-set,omitempty" yaml:"erc-integer-set,omitempty"`
+	ERCFloatSet                 []float64          `json:"erc-float-set,omitempty" yaml:"erc-float-set,omitempty"`
+	ERCFloatMean                float64            `json:"erc-float-mean" yaml:"erc-float-mean"`
+	ERCFloatStdDev              float64            `json:"erc-float-stddev" yaml:"erc-float-stddev"`
+}
+
+func (o Options) toFile() optionsFile {
+	f := optionsFile{
+		TopLevelPushCode:            o.TopLevelPushCode,
+		TopLevelPopCode:             o.TopLevelPopCode,
+		EvalPushLimit:               o.EvalPushLimit,
+		NewERCNameProbabilty:        o.NewERCNameProbabilty,
+		MaxPointsInProgram:          o.MaxPointsInProgram,
+		MaxPointsInRandomExpression: o.MaxPointsInRandomExpression,
+		MaxRandomFloat:              o.MaxRandomFloat,
+		MinRandomFloat:              o.MinRandomFloat,
+		MaxRandomInteger:            o.MaxRandomInteger,
+		MinRandomInteger:            o.MinRandomInteger,
+		Tracing:                     o.Tracing,
+		RandomSeed:                  o.RandomSeed,
+		ERCWeight:                   o.ERCWeight,
+		ERCIntegerSet:               o.ERCIntegerSet,
+		ERCFloatSet:                 o.ERCFloatSet,
+		ERCFloatMean:                o.ERCFloatMean,
+		ERCFloatStdDev:              o.ERCFloatStdDev,
+		Types:                       []string{},
+		Instructions:                []string{},
+	}
+
+	for _, instr := range sortedKeys(o.AllowedInstructions) {
+		f.Instructions = append(f.Instructions, formatInstruction(instr))
+	}
+
+	if len(o.InstructionWeights) > 0 {
+		f.InstructionWeights = make(map[string]float64)
+		for name, w := range o.InstructionWeights {
+			f.InstructionWeights[formatInstruction(name)] = w
+		}
+	}
+
+	// As with WriteTo, only the types that can be read back are written
+	for _, t := range sortedKeys(o.AllowedTypes) {
+		switch t {
+		case "boolean", "code", "float", "integer":
+			f.Types = append(f.Types, t)
+		}
+	}
+
+	return f
+}
+
+// fromFile returns the Options described by f. The types and instructions are
+// set as in the configuration file format, so instructions may be patterns,
+// and the exclusions are applied last. The settings are checked as in
+// ParseOptions.
+func (f optionsFile) fromFile() (Options, error) {
+	o := newOptions()
+
+	o.TopLevelPushCode = f.TopLevelPushCode
+	o.TopLevelPopCode = f.TopLevelPopCode
+	o.EvalPushLimit = f.EvalPushLimit
+	o.NewERCNameProbabilty = f.NewERCNameProbabilty
+	o.MaxPointsInProgram = f.MaxPointsInProgram
+	o.MaxPointsInRandomExpression = f.MaxPointsInRandomExpression
+	o.MaxRandomFloat = f.MaxRandomFloat
+	o.MinRandomFloat = f.MinRandomFloat
+	o.MaxRandomInteger = f.MaxRandomInteger
+	o.MinRandomInteger = f.MinRandomInteger
+	o.Tracing = f.Tracing
+	o.RandomSeed = f.RandomSeed
+	o.ERCWeight = f.ERCWeight
+	o.ERCIntegerSet = f.ERCIntegerSet
+	o.ERCFloatSet = f.ERCFloatSet
+	o.ERCFloatMean = f.ERCFloatMean
+	o.ERCFloatStdDev = f.ERCFloatStdDev
+
+	for _, t := range f.Types {
+		if err := o.set("type", t); err != nil {
+			return Options{}, err
+		}
+	}
+
+	for _, instr := range f.Instructions {
+		if err := o.set("instruction", instr); err != nil {
+			return Options{}, err
+		}
+	}
+
+	for name, w := range f.InstructionWeights {
+		if err := o.weightInstructions(name, w); err != nil {
+			return Options{}, err
+		}
+	}
+
+	for _, instr := range f.ExcludeInstructions {
+		if err := o.set("exclude-instruction", instr); err != nil {
+			return Options{}, err
+		}
+	}
+
+	if err := o.check(); err != nil {
+		return Options{}, err
+	}
+
+	return o, nil
+}
+
+// MarshalJSON encodes the options as a JSON object whose keys are the
+// parameter names of the configuration file format, with the types and
+// instructions as lists.
+func (o Options) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.toFile())
+}
+
+// UnmarshalJSON decodes options encoded by MarshalJSON. Missing keys keep
+// their default values, unknown keys are an error.
+func (o *Options) UnmarshalJSON(b []byte) error {
+	f := newOptions().toFile()
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&f); err != nil {
+		return err
+	}
+
+	parsed, err := f.fromFile()
+	if err != nil {
+		return err
+	}
+
+	*o = parsed
+	return nil
+}
+
+// MarshalYAML encodes the options like MarshalJSON does.
+func (o Options) MarshalYAML() (interface{}, error) {
+	return o.toFile(), nil
+}
+
+// UnmarshalYAML decodes options encoded by MarshalYAML. Missing keys keep
+// their default values.
+func (o *Options) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	f := newOptions().toFile()
+
+	if err := unmarshal(&f); err != nil {
+		return err
+	}
+
+	parsed, err := f.fromFile()
+	if err != nil {
+		return err
+	}
+
+	*o = parsed
+	return nil
+}
+
+// ReadOptionsJSON reads options encoded as JSON from the given io.Reader.
+func ReadOptionsJSON(r io.Reader) (Options, error) {
+	var o Options
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Options{}, err
+	}
+
+	if err := json.Unmarshal(b, &o); err != nil {
+		return Options{}, err
+	}
+
+	return o, nil
+}
+
+// ReadOptionsYAML reads options encoded as YAML from the given io.Reader.
+// Unknown keys are an error.
+func ReadOptionsYAML(r io.Reader) (Options, error) {
+	var o Options
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Options{}, err
+	}
+
+	if err := yaml.UnmarshalStrict(b, &o); err != nil {
+		return Options{}, err
+	}
+
+	return o, nil
+}
+
+// optionsFormat returns the format of the given options file, judging by its
+// extension: "json", "yaml" or "text".
+func optionsFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+
+	return "text"
+}
+
+// WriteOptionsToFile writes the options to the given file. Files ending in
+// .json are written as JSON, files ending in .yaml or .yml as YAML and all
+// others in the configuration file format.
+func WriteOptionsToFile(filename string, o Options) error {
+	var b []byte
+	var err error
+
+	switch optionsFormat(filename) {
+	case "json":
+		b, err = json.MarshalIndent(o, "", "  ")
+		b = append(b, '\n')
+	case "yaml":
+		b, err = yaml.Marshal(o)
+	default:
+		b = []byte(o.String())
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not encode options: %v", err)
+	}
+
+	return ioutil.WriteFile(filename, b, 0644)
+}
@@ -1,11 +1,18 @@
 package gopush_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/DataWraith/gopush"
+	"gopkg.in/yaml.v2"
 )
 
 func TestParsingEmptyConfigurationFile(t *testing.T) {
@@ -17,6 +24,7 @@ func TestParsingEmptyConfigurationFile(t *testing.T) {
 	defaultConfig := gopush.Options{
 		AllowedInstructions:         make(map[string]struct{}),
 		AllowedTypes:                make(map[string]struct{}),
+		ERCWeight:                   1,
 		EvalPushLimit:               1000,
 		MaxPointsInProgram:          100,
 		MaxPointsInRandomExpression: 25,
@@ -56,6 +64,16 @@ var optionParseErrorTests = []struct {
 	{"top-level-pop-code foo", "could not parse \"foo\" as boolean"},
 	{"tracing foo", "could not parse \"foo\" as boolean"},
 	{"foo bar", "unknown parameter \"foo\""},
+	{"erc-weight foo", "could not parse \"foo\" as float"},
+	{"instruction-weight integer.+", "expected weight to follow instruction-weight integer.+"},
+	{"instruction-weight integer.+ foo", "could not parse \"foo\" as float"},
+	{"instruction-weight foo.* 1", "instruction pattern \"foo.*\" matches no instructions"},
+	{"instruction-weight integer.+ -1", "INSTRUCTION-WEIGHT must be at least 0, got -1"},
+	{"erc-weight -1", "ERC-WEIGHT must be above 0, got -1; use INSTRUCTION-WEIGHT to turn off single ERCs"},
+	{"erc-weight 0", "ERC-WEIGHT must be above 0, got 0; use INSTRUCTION-WEIGHT to turn off single ERCs"},
+	{"erc-integer-set 1 foo", "could not parse \"foo\" as integer"},
+	{"erc-float-set 1 foo", "could not parse \"foo\" as float"},
+	{"erc-float-stddev -1", "ERC-FLOAT-STDDEV must be at least 0, got -1"},
 
 	{"max-points-in-random-expressions -7", "MAX-POINTS-IN-RANDOM-EXPRESSIONS must be at least 1, got -7"},
 	{"max-points-in-program -7", "MAX-POINTS-IN-PROGRAM must be at least 1, got -7"},
@@ -74,3 +92,276 @@ func TestParseErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestOptionsRoundTrip(t *testing.T) {
+	configs := []string{
+		"",
+		"min-random-float -0.1\nmax-random-float 1e-300\nrandom-seed -42\ntracing true",
+		"type float\ntype integer\ninstruction float.+\ninstruction integer.+\ninstruction name.dup",
+		"erc-integer-set 0 1 -2\nerc-float-set 0.5 3\nerc-float-mean 1\nerc-float-stddev 0.25",
+		"type integer\ninstruction integer.?*\ninstruction-weight integer.* 2.5\ninstruction-weight integer-erc 0\nerc-weight 0.2",
+		"instruction integer.*\ninstruction string.\\*\ninstruction-weight string.\\? 2",
+	}
+
+	for _, config := range configs {
+		o, _ := gopush.ParseOptions(config)
+
+		parsed, err := gopush.ParseOptions(o.String())
+		if err != nil {
+			t.Fatalf("unexpected error while parsing %q: %v", o.String(), err)
+		}
+
+		if !reflect.DeepEqual(parsed, o) {
+			t.Errorf("expected %q to parse back to the same options", o.String())
+		}
+
+		if parsed.String() != o.String() {
+			t.Errorf("expected the output to be canonical, got %q and %q", o.String(), parsed.String())
+		}
+	}
+}
+
+func TestOptionsWriteCustomTypes(t *testing.T) {
+	o, _ := gopush.ParseOptions("type integer")
+	o.RegisterStack("string", &gopush.Stack{Functions: map[string]func(){"dup": func() {}}})
+
+	s := o.String()
+
+	if !strings.Contains(s, "\n# type STRING") || !strings.Contains(s, "\ninstruction STRING.DUP\n") {
+		t.Errorf("expected custom type as comment and its instructions, got %q", s)
+	}
+
+	if _, err := gopush.ParseOptions(s); err != nil {
+		t.Errorf("unexpected error while parsing %q: %v", s, err)
+	}
+}
+
+func TestOptionsJSONAndYAML(t *testing.T) {
+	o, _ := gopush.ParseOptions("evalpush-limit 50\nmin-random-float -0.5\ntype integer\ntype float\ninstruction integer.+\ninstruction float.dup")
+
+	b, err := json.Marshal(o)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fromJSON, err := gopush.ReadOptionsJSON(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("unexpected error while reading %s: %v", b, err)
+	}
+
+	if !reflect.DeepEqual(fromJSON, o) {
+		t.Errorf("expected %s to decode to the original options", b)
+	}
+
+	b, err = yaml.Marshal(o)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fromYAML, err := gopush.ReadOptionsYAML(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("unexpected error while reading %s: %v", b, err)
+	}
+
+	if !reflect.DeepEqual(fromYAML, o) {
+		t.Errorf("expected %s to decode to the original options", b)
+	}
+
+	partial, err := gopush.ReadOptionsJSON(strings.NewReader(`{"evalpush-limit": 50, "types": ["INTEGER"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if partial.EvalPushLimit != 50 || partial.MaxPointsInProgram != 100 || len(partial.AllowedTypes) != 1 {
+		t.Errorf("expected missing keys to keep their defaults, got %+v", partial)
+	}
+}
+
+var optionsJSONErrorTests = []struct {
+	toParse       string
+	expectedError string
+}{
+	{`{"evalpush-limit": 0}`, "EVALPUSH-LIMIT must be at least 1, got 0"},
+	{`{"new-erc-name-probability": 2}`, "NEW-ERC-NAME-PROBABILITY must be between 0 and 1 inclusive, got 2"},
+	{`{"min-random-integer": 10, "max-random-integer": 0}`, "MIN-RANDOM-INTEGER (10) must be less than or equal to MAX-RANDOM-INTEGER (0)"},
+	{`{"types": ["foo"]}`, "unknown type: \"foo\""},
+	{`{"foo": 1}`, "json: unknown field \"foo\""},
+}
+
+func TestOptionsJSONErrors(t *testing.T) {
+	for _, pe := range optionsJSONErrorTests {
+		_, err := gopush.ReadOptionsJSON(strings.NewReader(pe.toParse))
+		if err == nil || err.Error() != pe.expectedError {
+			t.Errorf("unexpected error while parsing %s: %v, expected %q", pe.toParse, err, pe.expectedError)
+		}
+	}
+
+	if _, err := gopush.ReadOptionsYAML(strings.NewReader("max-points-in-program: 0\n")); err == nil {
+		t.Error("expected an error for an invalid YAML setting")
+	}
+
+	if _, err := gopush.ReadOptionsYAML(strings.NewReader("foo: 1\n")); err == nil {
+		t.Error("expected an error for an unknown YAML key")
+	}
+}
+
+func TestOptionsFileFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopush")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	o, _ := gopush.ParseOptions("random-seed 7\ntype boolean\ninstruction boolean.and")
+
+	for _, name := range []string{"options.json", "options.yaml", "options.yml", "options.push"} {
+		filename := filepath.Join(dir, name)
+
+		if err := gopush.WriteOptionsToFile(filename, o); err != nil {
+			t.Fatalf("unexpected error while writing %v: %v", name, err)
+		}
+
+		read, err := gopush.ReadOptionsFromFile(filename)
+		if err != nil {
+			t.Fatalf("unexpected error while reading %v: %v", name, err)
+		}
+
+		if !reflect.DeepEqual(read, o) {
+			t.Errorf("expected %v to read back the written options", name)
+		}
+	}
+}
+
+func TestInstructionPatterns(t *testing.T) {
+	o, err := gopush.ParseOptions("instruction integer.?*\nexclude-instruction INTEGER.RAND\nexclude-instruction integer.yank*\ninstruction *.dup\ninstruction float.*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, instr := range []string{"integer.+", "integer.*", "integer.dup", "float.*", "boolean.dup", "code.dup", "exec.dup", "name.dup"} {
+		if _, ok := o.AllowedInstructions[instr]; !ok {
+			t.Errorf("expected %v to be allowed", instr)
+		}
+	}
+
+	for _, instr := range []string{"integer.rand", "integer.yank", "integer.yankdup", "float.+"} {
+		if _, ok := o.AllowedInstructions[instr]; ok {
+			t.Errorf("expected %v not to be allowed", instr)
+		}
+	}
+
+	// The names of builtin instructions are never patterns, so the
+	// configuration files of Push3 and Clojush keep their meaning
+	for _, tt := range []struct {
+		config   string
+		expected []string
+	}{
+		{"instruction INTEGER.*", []string{"integer.*"}},
+		{"instruction code.do*", []string{"code.do*"}},
+		{"instruction code.do\\*\ninstruction code.do?count", []string{"code.do*", "code.do*count"}},
+		{"instruction integer.?*\nexclude-instruction integer.?*\ninstruction integer.*", []string{"integer.*"}},
+		{"instruction string.length", []string{"string.length"}},
+	} {
+		o, err := gopush.ParseOptions(tt.config)
+		if err != nil {
+			t.Errorf("unexpected error while parsing %q: %v", tt.config, err)
+			continue
+		}
+
+		var allowed []string
+		for instr := range o.AllowedInstructions {
+			allowed = append(allowed, instr)
+		}
+		sort.Strings(allowed)
+
+		if !reflect.DeepEqual(allowed, tt.expected) {
+			t.Errorf("expected %q to allow %v, got %v", tt.config, tt.expected, allowed)
+		}
+	}
+
+	errorTests := []struct {
+		toParse       string
+		expectedError string
+	}{
+		{"instruction foo.*", "instruction pattern \"foo.*\" matches no instructions"},
+		{"instruction string.*", "instruction pattern \"string.*\" matches no instructions"},
+		{"exclude-instruction integer.rand", "exclude-instruction \"integer.rand\" matches no allowed instructions"},
+	}
+
+	for _, et := range errorTests {
+		if _, err := gopush.ParseOptions(et.toParse); err == nil || err.Error() != et.expectedError {
+			t.Errorf("unexpected error while parsing %q: %v, expected %q", et.toParse, err, et.expectedError)
+		}
+	}
+}
+
+func TestIncludeOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopush")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"base.push":      "type integer\ninstruction integer.?*\nevalpush-limit 50\n",
+		"sub/main.push":  "include ../base.push\nexclude-instruction integer.rand\nevalpush-limit 70\n",
+		"cycle/a.push":   "include b.push\n",
+		"cycle/b.push":   "include a.push\n",
+		"missing/a.push": "include nonexistent.push\n",
+	}
+
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	o, err := gopush.ReadOptionsFromFile(filepath.Join(dir, "sub/main.push"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := o.AllowedInstructions["integer.+"]; !ok || o.EvalPushLimit != 70 || len(o.AllowedTypes) != 1 {
+		t.Errorf("expected the included file to be read, got %+v", o)
+	}
+
+	if _, ok := o.AllowedInstructions["integer.rand"]; ok {
+		t.Error("expected integer.rand to be excluded")
+	}
+
+	for _, name := range []string{"cycle/a.push", "missing/a.push"} {
+		if _, err := gopush.ReadOptionsFromFile(filepath.Join(dir, name)); err == nil {
+			t.Errorf("expected an error reading %v", name)
+		}
+	}
+}
+
+func TestInstructionWeights(t *testing.T) {
+	o, err := gopush.ParseOptions("instruction-weight integer.?* 3\ninstruction-weight INTEGER.+ 5 # comment\nerc-weight 0.2\ninstruction-weight name-erc 0\ntype integer")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.InstructionWeights["integer.+"] != 5 || o.InstructionWeights["integer.dup"] != 3 || o.InstructionWeights["name-erc"] != 0 {
+		t.Errorf("unexpected instruction weights: %v", o.InstructionWeights)
+	}
+
+	if o.ERCWeight != 0.2 || len(o.AllowedTypes) != 1 {
+		t.Errorf("unexpected options: %+v", o)
+	}
+
+	b, err := json.Marshal(o)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fromJSON, err := gopush.ReadOptionsJSON(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("unexpected error while reading %s: %v", b, err)
+	}
+
+	if !reflect.DeepEqual(fromJSON, o) {
+		t.Errorf("expected %s to decode to the original options", b)
+	}
+}
@@ -1,7 +1,6 @@
 package gopush
 
 import (
-	"errors"
 	"unicode"
 )
 
@@ -42,21 +41,57 @@ func getToken(program string) (token, remainder string) {
 	return program, ""
 }
 
-func getToParen(program string) (subprogram, remainder string, err error) {
-	parenBalance := 1
+// codeToken is a token of a Push program and its position in the source.
+type codeToken struct {
+	text string
+	pos  Position
+}
+
+// lexCode splits a Push program into tokens. Tokens are separated by
+// whitespace, and the parentheses are tokens of their own. A # or ; at the
+// start of a token starts a comment that extends to the end of the line.
+func lexCode(program string) []codeToken {
+	var tokens []codeToken
+
+	start := -1
+	var startPos Position
+
+	pos := Position{Line: 1, Column: 1}
+	comment := false
+
 	for i, r := range program {
-		switch r {
-		case '(':
-			parenBalance++
-		case ')':
-			parenBalance--
+		switch {
+		case comment:
+
+		case r == '(' || r == ')' || unicode.IsSpace(r) || ((r == '#' || r == ';') && start < 0):
+			if start >= 0 {
+				tokens = append(tokens, codeToken{text: program[start:i], pos: startPos})
+				start = -1
+			}
+
+			if r == '(' || r == ')' {
+				tokens = append(tokens, codeToken{text: string(r), pos: pos})
+			}
+
+			comment = r == '#' || r == ';'
+
+		case start < 0:
+			start, startPos = i, pos
 		}
 
-		if parenBalance == 0 {
-			return program[:i], program[i+1:], nil
+		pos.Column++
+		if r == '\n' {
+			pos.Line++
+			pos.Column = 1
+			comment = false
 		}
 	}
-	return "", "", errors.New("unbalanced parentheses")
+
+	if start >= 0 {
+		tokens = append(tokens, codeToken{text: program[start:], pos: startPos})
+	}
+
+	return tokens
 }
 
 func getParameterSettingPair(s string) (parameter, setting, remainder string) {
@@ -71,3 +106,19 @@ func getParameterSettingPair(s string) (parameter, setting, remainder string) {
 
 	return parameter, setting, s
 }
+
+// getLineTokens returns the tokens remaining on the current line of s. A
+// comment ends the line.
+func getLineTokens(s string) (tokens []string, remainder string) {
+	var t string
+
+	for {
+		s = ignoreWhiteSpace(s, false)
+		if s == "" || s[0] == '\n' || s[0] == '#' {
+			return tokens, s
+		}
+
+		t, s = getToken(s)
+		tokens = append(tokens, t)
+	}
+}
@@ -0,0 +1,183 @@
+package gopush
+
+import (
+	"math"
+	"strings"
+)
+
+// PlushGene is a single gene of a Plush genome. It holds an instruction (or
+// literal), the number of blocks to close after the instruction and whether the
+// gene is silent, i.e. ignored during translation.
+type PlushGene struct {
+	Instruction string
+	Close       int
+	Silent      bool
+}
+
+// PlushGenome is a linear genome that can be translated into a Push program.
+type PlushGenome []PlushGene
+
+// PlushBlocks holds the number of code blocks the given instructions expect
+// to follow them on the EXEC stack. Translate opens that many blocks after
+// each of these instructions. Instructions of custom stacks can be added to
+// this map.
+var PlushBlocks = map[string]int{
+	"CODE.QUOTE":    1,
+	"EXEC.=":        2,
+	"EXEC.DEFINE":   1,
+	"EXEC.DO*COUNT": 1,
+	"EXEC.DO*RANGE": 1,
+	"EXEC.DO*TIMES": 1,
+	"EXEC.DUP":      1,
+	"EXEC.IF":       2,
+	"EXEC.K":        2,
+	"EXEC.POP":      1,
+	"EXEC.ROT":      3,
+	"EXEC.S":        3,
+	"EXEC.SHOVE":    1,
+	"EXEC.SWAP":     2,
+	"EXEC.Y":        1,
+}
+
+// plushCloseProbabilities holds the probabilities of a random gene closing 0,
+// 1, 2 or 3 blocks.
+var plushCloseProbabilities = []float64{0.772, 0.206, 0.021, 0.001}
+
+type plushBlock struct {
+	list      []Code
+	remaining int
+}
+
+// Translate translates the genome into a Push program. Every instruction
+// listed in PlushBlocks opens a new block, and each gene closes as many of the
+// open blocks as its Close count says. When a block is closed and the
+// instruction that opened it requires further blocks, the next one is opened
+// immediately. Blocks still open at the end of the genome are closed
+// implicitly, and closing more blocks than are open has no effect.
+func (g PlushGenome) Translate() Code {
+	stack := []*plushBlock{&plushBlock{}}
+
+	for _, gene := range g {
+		if gene.Silent {
+			continue
+		}
+
+		top := stack[len(stack)-1]
+		top.list = append(top.list, Code{Length: 1, Literal: gene.Instruction})
+
+		if n := PlushBlocks[strings.ToUpper(gene.Instruction)]; n > 0 {
+			stack = append(stack, &plushBlock{remaining: n - 1})
+		}
+
+		for j := 0; j < gene.Close && len(stack) > 1; j++ {
+			stack = closePlushBlock(stack)
+		}
+	}
+
+	for len(stack) > 1 {
+		stack = closePlushBlock(stack)
+	}
+
+	return Code{List: stack[0].list}.Normalize()
+}
+
+func closePlushBlock(stack []*plushBlock) []*plushBlock {
+	top := stack[len(stack)-1]
+	stack = stack[:len(stack)-1]
+
+	parent := stack[len(stack)-1]
+	parent.list = append(parent.list, Code{List: top.list})
+
+	if top.remaining > 0 {
+		stack = append(stack, &plushBlock{remaining: top.remaining - 1})
+	}
+
+	return stack
+}
+
+// RandomPlushGenome returns a random Plush genome with the given number of
+// genes. The instructions are chosen like the ones in RandomCode, including
+// ephemeral random constants.
+func (i *Interpreter) RandomPlushGenome(size int) PlushGenome {
+	g := make(PlushGenome, size)
+	for j := range g {
+		g[j] = i.randomPlushGene()
+	}
+	return g
+}
+
+func (i *Interpreter) randomPlushGene() PlushGene {
+	gene := PlushGene{Instruction: i.randomInstruction().Literal}
+
+	r := i.Rand.Float64()
+	for _, p := range plushCloseProbabilities {
+		if r < p {
+			break
+		}
+		r -= p
+		gene.Close++
+	}
+
+	return gene
+}
+
+// UMAD performs uniform mutation by addition and deletion. A random gene is
+// inserted before or after every gene of g with probability addRate, and then
+// every gene of the result is deleted with probability deleteRate. If the
+// result has more genes than MaxPointsInProgram, g is returned unchanged.
+func (i *Interpreter) UMAD(g PlushGenome, addRate, deleteRate float64) PlushGenome {
+	added := make(PlushGenome, 0, len(g))
+	for _, gene := range g {
+		if i.Rand.Float64() >= addRate {
+			added = append(added, gene)
+			continue
+		}
+
+		if i.Rand.Intn(2) == 0 {
+			added = append(added, i.randomPlushGene(), gene)
+		} else {
+			added = append(added, gene, i.randomPlushGene())
+		}
+	}
+
+	child := added[:0]
+	for _, gene := range added {
+		if i.Rand.Float64() >= deleteRate {
+			child = append(child, gene)
+		}
+	}
+
+	if len(child) > i.Options.MaxPointsInProgram {
+		return g
+	}
+
+	return child
+}
+
+// Alternation recombines g1 and g2 by copying genes from one parent, starting
+// with a randomly chosen one, and switching to the other parent after each gene
+// with probability alternationRate. When switching, the position in the genome
+// is shifted by a Gaussian amount with standard deviation alignmentDeviation.
+// The child ends when the end of the current parent is reached or it has
+// MaxPointsInProgram genes.
+func (i *Interpreter) Alternation(g1, g2 PlushGenome, alternationRate, alignmentDeviation float64) PlushGenome {
+	parents := [2]PlushGenome{g1, g2}
+	current := i.Rand.Intn(2)
+
+	child := make(PlushGenome, 0, len(parents[current]))
+
+	for idx := 0; idx < len(parents[current]) && len(child) < i.Options.MaxPointsInProgram; {
+		child = append(child, parents[current][idx])
+		idx++
+
+		if i.Rand.Float64() < alternationRate {
+			current = 1 - current
+			idx += int(math.Floor(i.Rand.NormFloat64()*alignmentDeviation + 0.5))
+			if idx < 0 {
+				idx = 0
+			}
+		}
+	}
+
+	return child
+}
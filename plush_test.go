@@ -0,0 +1,72 @@
+package gopush_test
+
+import (
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+var plushTranslationTests = []struct {
+	genome   gopush.PlushGenome
+	expected string
+}{
+	{gopush.PlushGenome{}, "( )"},
+	{
+		gopush.PlushGenome{{"1", 0, false}, {"2", 3, false}, {"INTEGER.+", 0, false}},
+		"( 1 2 INTEGER.+ )",
+	},
+	{
+		gopush.PlushGenome{{"EXEC.IF", 0, false}, {"1", 1, false}, {"2", 1, false}, {"INTEGER.+", 0, false}},
+		"( EXEC.IF ( 1 ) ( 2 ) INTEGER.+ )",
+	},
+	{
+		gopush.PlushGenome{{"exec.do*times", 0, false}, {"A", 0, false}, {"B", 0, true}},
+		"( exec.do*times ( A ) )",
+	},
+	{
+		gopush.PlushGenome{{"EXEC.IF", 0, false}, {"EXEC.Y", 0, false}},
+		"( EXEC.IF ( EXEC.Y ( ) ) ( ) )",
+	},
+}
+
+func TestPlushTranslation(t *testing.T) {
+	for _, tt := range plushTranslationTests {
+		c := tt.genome.Translate()
+
+		if c.String() != tt.expected {
+			t.Errorf("expected %v to translate to %q, got %q", tt.genome, tt.expected, c)
+		}
+
+		checkLength(t, c)
+	}
+}
+
+func TestPlushOperators(t *testing.T) {
+	interpreter := newVariationInterpreter()
+
+	g1 := interpreter.RandomPlushGenome(10)
+	g2 := interpreter.RandomPlushGenome(15)
+
+	if len(g1) != 10 || len(g2) != 15 {
+		t.Fatalf("expected random genomes of length 10 and 15, got %v and %v", len(g1), len(g2))
+	}
+
+	if m := interpreter.UMAD(g1, 0, 0); len(m) != len(g1) {
+		t.Errorf("expected UMAD with rates 0 to leave the genome unchanged, got %v", m)
+	}
+
+	if m := interpreter.UMAD(g1, 0, 1); len(m) != 0 {
+		t.Errorf("expected UMAD with deletion rate 1 to delete all genes, got %v", m)
+	}
+
+	for n := 0; n < 100; n++ {
+		child := interpreter.Alternation(g1, g2, 0.5, 2)
+		if len(child) > interpreter.Options.MaxPointsInProgram {
+			t.Fatalf("expected alternation to respect MaxPointsInProgram, got %v genes", len(child))
+		}
+	}
+
+	if child := interpreter.Alternation(g1, g1, 0, 0); len(child) != len(g1) {
+		t.Errorf("expected alternation without switching to copy a parent, got %v", child)
+	}
+}
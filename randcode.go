@@ -34,14 +34,5 @@ func (i *Interpreter) randomCodeWithSize(numPoints int64) Code {
 		codeFragments[j], codeFragments[k] = codeFragments[k], codeFragments[j]
 	}
 
-	c := Code{
-		Length: 0,
-		List:   codeFragments,
-	}
-
-	for _, fragment := range codeFragments {
-		c.Length += fragment.Length
-	}
-
-	return c
+	return newList(codeFragments)
 }
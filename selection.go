@@ -0,0 +1,220 @@
+package gopush
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// LexicaseSelection selects n parents from a population using lexicase
+// selection. errors holds one error vector per individual, with one entry per
+// fitness case. For every selection event the fitness cases are shuffled and
+// candidates that are not elite on the current case are removed until a single
+// candidate remains or the cases are exhausted, in which case a random
+// survivor is chosen. The returned slice holds indices into errors.
+func LexicaseSelection(errors [][]float64, n int, rng *rand.Rand) []int {
+	return lexicaseSelection(errors, allCases(errors), nil, n, rng)
+}
+
+// EpsilonLexicaseSelection selects n parents from a population using
+// epsilon-lexicase selection. It works like LexicaseSelection, but a candidate
+// survives a fitness case if its error is within epsilon of the best error on
+// that case. Epsilon is determined automatically for every case as the median
+// absolute deviation of the population's errors on that case.
+func EpsilonLexicaseSelection(errors [][]float64, n int, rng *rand.Rand) []int {
+	cases := allCases(errors)
+	return lexicaseSelection(errors, cases, MADEpsilons(errors), n, rng)
+}
+
+// DownsampledLexicaseSelection selects n parents from a population using
+// down-sampled lexicase selection. A random subset of the fitness cases,
+// containing the given fraction of all cases (but at least one case), is drawn
+// once and then used for all n selection events.
+func DownsampledLexicaseSelection(errors [][]float64, n int, rate float64, rng *rand.Rand) []int {
+	cases := allCases(errors)
+
+	size := int(math.Ceil(rate * float64(len(cases))))
+	if size < 1 {
+		size = 1
+	}
+
+	if size < len(cases) {
+		perm := rng.Perm(len(cases))
+		cases = perm[:size]
+		sort.Ints(cases)
+	}
+
+	return lexicaseSelection(errors, cases, nil, n, rng)
+}
+
+// TournamentSelection selects n parents from a population using tournament
+// selection. Each parent is the individual with the lowest total error among
+// size randomly chosen individuals. Ties go to the individual chosen first, so
+// equally good individuals are selected equally often.
+func TournamentSelection(errors [][]float64, n int, size int, rng *rand.Rand) []int {
+	selected := make([]int, 0, n)
+
+	if len(errors) == 0 {
+		return selected
+	}
+
+	cases := allCases(errors)
+	totals := make([]float64, len(errors))
+	for j, ev := range errors {
+		totals[j] = totalError(ev, cases)
+	}
+
+	for ; n > 0; n-- {
+		best := rng.Intn(len(errors))
+		for k := 1; k < size; k++ {
+			if cand := rng.Intn(len(errors)); totals[cand] < totals[best] {
+				best = cand
+			}
+		}
+		selected = append(selected, best)
+	}
+
+	return selected
+}
+
+// MADEpsilons returns the median absolute deviation of the population's errors
+// for each fitness case. These are the epsilons used by
+// EpsilonLexicaseSelection.
+func MADEpsilons(errors [][]float64) []float64 {
+	cases := allCases(errors)
+	epsilons := make([]float64, len(cases))
+	column := make([]float64, len(errors))
+
+	for _, c := range cases {
+		for j, ev := range errors {
+			column[j] = caseError(ev, c)
+		}
+		epsilons[c] = medianAbsoluteDeviation(column)
+	}
+
+	return epsilons
+}
+
+func lexicaseSelection(errors [][]float64, cases []int, epsilons []float64, n int, rng *rand.Rand) []int {
+	selected := make([]int, 0, n)
+
+	if len(errors) == 0 {
+		return selected
+	}
+
+	candidates := make([]int, len(errors))
+	order := make([]int, len(cases))
+
+	for ; n > 0; n-- {
+		for j := range candidates {
+			candidates[j] = j
+		}
+
+		copy(order, cases)
+		for j := range order {
+			k := rng.Intn(j + 1)
+			order[j], order[k] = order[k], order[j]
+		}
+
+		for _, c := range order {
+			if len(candidates) == 1 {
+				break
+			}
+
+			best := math.Inf(1)
+			for _, cand := range candidates {
+				if e := caseError(errors[cand], c); e < best {
+					best = e
+				}
+			}
+
+			threshold := best
+			if epsilons != nil {
+				threshold += epsilons[c]
+			}
+
+			survivors := candidates[:0]
+			for _, cand := range candidates {
+				if caseError(errors[cand], c) <= threshold {
+					survivors = append(survivors, cand)
+				}
+			}
+			candidates = survivors
+		}
+
+		selected = append(selected, candidates[rng.Intn(len(candidates))])
+		candidates = candidates[:cap(candidates)]
+	}
+
+	return selected
+}
+
+// allCases returns the indices of all fitness cases of the population. The
+// number of cases is taken from the longest error vector.
+func allCases(errors [][]float64) []int {
+	numCases := 0
+	for _, ev := range errors {
+		if len(ev) > numCases {
+			numCases = len(ev)
+		}
+	}
+
+	cases := make([]int, numCases)
+	for j := range cases {
+		cases[j] = j
+	}
+
+	return cases
+}
+
+// caseError returns the error on the given case. Missing entries and NaN are
+// treated as an infinitely bad error.
+func caseError(ev []float64, c int) float64 {
+	if c >= len(ev) || math.IsNaN(ev[c]) {
+		return math.Inf(1)
+	}
+	return ev[c]
+}
+
+// totalError returns the sum of the errors on the given cases.
+func totalError(ev []float64, cases []int) float64 {
+	total := 0.0
+	for _, c := range cases {
+		total += caseError(ev, c)
+	}
+	return total
+}
+
+func median(xs []float64) float64 {
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+// medianAbsoluteDeviation returns the median absolute deviation of the finite
+// values in xs, or zero if there are none.
+func medianAbsoluteDeviation(xs []float64) float64 {
+	finite := make([]float64, 0, len(xs))
+	for _, x := range xs {
+		if !math.IsInf(x, 0) && !math.IsNaN(x) {
+			finite = append(finite, x)
+		}
+	}
+
+	if len(finite) == 0 {
+		return 0
+	}
+
+	m := median(finite)
+	for j, x := range finite {
+		finite[j] = math.Abs(x - m)
+	}
+
+	return median(finite)
+}
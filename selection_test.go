@@ -0,0 +1,103 @@
+package gopush_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+var selectionErrors = [][]float64{
+	{0, 5, 5, 5},
+	{5, 0, 5, 5},
+	{1, 1, 1, 1},
+	{9, 9, 9, 9},
+}
+
+func TestLexicaseSelectionNeverPicksDominated(t *testing.T) {
+	rng := rand.New(rand.NewSource(1138))
+
+	for _, p := range gopush.LexicaseSelection(selectionErrors, 200, rng) {
+		if p == 3 {
+			t.Fatal("expected lexicase selection never to select a dominated individual")
+		}
+	}
+}
+
+func TestLexicaseSelectionIsDeterministic(t *testing.T) {
+	s1 := gopush.LexicaseSelection(selectionErrors, 50, rand.New(rand.NewSource(42)))
+	s2 := gopush.LexicaseSelection(selectionErrors, 50, rand.New(rand.NewSource(42)))
+
+	if !reflect.DeepEqual(s1, s2) {
+		t.Errorf("expected identical seeds to produce identical selections, got %v and %v", s1, s2)
+	}
+}
+
+func TestEpsilonLexicaseSelection(t *testing.T) {
+	errors := [][]float64{
+		{0.0, 10},
+		{0.1, 0},
+		{5.0, 10},
+	}
+
+	eps := gopush.MADEpsilons(errors)
+	if eps[0] != 0.1 {
+		t.Errorf("expected MAD of case 0 to be 0.1, got %v", eps[0])
+	}
+
+	// With epsilon, individual 1 is within epsilon of the best on case 0
+	// and strictly best on case 1, so it must always win.
+	rng := rand.New(rand.NewSource(1138))
+	for _, p := range gopush.EpsilonLexicaseSelection(errors, 100, rng) {
+		if p != 1 {
+			t.Fatalf("expected epsilon-lexicase selection to select individual 1, got %v", p)
+		}
+	}
+}
+
+func TestDownsampledLexicaseSelection(t *testing.T) {
+	rng := rand.New(rand.NewSource(1138))
+
+	// A single sampled case is enough to rule out individual 3
+	for _, p := range gopush.DownsampledLexicaseSelection(selectionErrors, 100, 0.01, rng) {
+		if p == 3 {
+			t.Fatal("expected down-sampled lexicase selection never to select a dominated individual")
+		}
+	}
+}
+
+func TestTournamentSelection(t *testing.T) {
+	rng := rand.New(rand.NewSource(1138))
+
+	// A tournament of one is a random choice, even of dominated individuals
+	counts := make([]int, len(selectionErrors))
+	for _, p := range gopush.TournamentSelection(selectionErrors, 200, 1, rng) {
+		counts[p]++
+	}
+
+	for p, n := range counts {
+		if n == 0 {
+			t.Errorf("expected tournaments of size 1 to select individual %v, got %v", p, counts)
+		}
+	}
+
+	// Large tournaments practically always include the best individual
+	for _, p := range gopush.TournamentSelection(selectionErrors, 100, 50, rng) {
+		if p != 2 {
+			t.Fatalf("expected large tournaments to select individual 2, got %v", p)
+		}
+	}
+
+	// Ties are not broken by position
+	errors := [][]float64{{1, 1}, {0, 2}, {5, 5}}
+
+	counts = make([]int, len(errors))
+	for _, p := range gopush.TournamentSelection(errors, 200, 50, rng) {
+		counts[p]++
+	}
+
+	if counts[0] == 0 || counts[1] == 0 || counts[2] != 0 {
+		t.Errorf("expected individuals 0 and 1 to share the selections, got %v", counts)
+	}
+}
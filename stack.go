@@ -6,6 +6,12 @@ package gopush
 type Stack struct {
 	Stack     []interface{}
 	Functions map[string]func()
+
+	// ParseLiteral optionally recognizes literals of the stack's data
+	// type in programs. If it returns true, the returned value is pushed
+	// onto the stack. The builtin literals (integers, floats and booleans)
+	// take precedence.
+	ParseLiteral func(literal string) (interface{}, bool)
 }
 
 // Peek returns the topmost item on the stack, or an empty struct if the stack
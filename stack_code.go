@@ -2,7 +2,6 @@ package gopush
 
 import (
 	"fmt"
-	"reflect"
 	"strings"
 )
 
@@ -19,7 +18,7 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 		c1 := interpreter.Stacks["code"].Pop().(Code)
 		c2 := interpreter.Stacks["code"].Pop().(Code)
 
-		if reflect.DeepEqual(c1, c2) {
+		if c1.Equal(c2) {
 			interpreter.Stacks["boolean"].Push(true)
 		} else {
 			interpreter.Stacks["boolean"].Push(false)
@@ -87,11 +86,7 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 		if len(c.List) == 0 {
 			interpreter.Stacks["code"].Push(Code{})
 		} else {
-			cdr := Code{
-				Length: c.Length - c.List[0].Length,
-				List:   c.List[1:],
-			}
-			interpreter.Stacks["code"].Push(cdr)
+			interpreter.Stacks["code"].Push(newList(c.List[1:]))
 		}
 	}
 
@@ -111,12 +106,7 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 			c2 = Code{Length: 1, List: []Code{c2}}
 		}
 
-		c := Code{
-			Length: c1.Length + c2.Length,
-			List:   append(c2.List, c1.List...),
-		}
-
-		interpreter.Stacks["code"].Push(c)
+		interpreter.Stacks["code"].Push(newList(append(c2.List, c1.List...)))
 	}
 
 	s.Functions["container"] = func() {
@@ -205,7 +195,7 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 
 		c := interpreter.Stacks["code"].Pop().(Code)
 
-		err := interpreter.runCode(c)
+		err := interpreter.runCode(c, -1)
 		if err != nil {
 			panic(err)
 		}
@@ -221,7 +211,7 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 		c := interpreter.Stacks["code"].Pop().(Code)
 		interpreter.Stacks["code"].Pop()
 
-		err := interpreter.runCode(c)
+		err := interpreter.runCode(c, -1)
 		if err != nil {
 			panic(err)
 		}
@@ -239,16 +229,13 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 			return
 		}
 
-		toPush := Code{
-			Length: 4 + c.Length,
-			List: []Code{
-				Code{Length: 1, Literal: "0"},
-				Code{Length: 1, Literal: fmt.Sprint(i)},
-				Code{Length: 1, Literal: "CODE.QUOTE"},
-				c,
-				Code{Length: 1, Literal: "CODE.DO*RANGE"},
-			},
-		}
+		toPush := newList([]Code{
+			Code{Length: 1, Literal: "0"},
+			Code{Length: 1, Literal: fmt.Sprint(i)},
+			Code{Length: 1, Literal: "CODE.QUOTE"},
+			c,
+			Code{Length: 1, Literal: "CODE.DO*RANGE"},
+		})
 
 		interpreter.Stacks["code"].Push(toPush)
 	}
@@ -294,19 +281,16 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 			return
 		}
 
-		toPush := Code{
-			Length: 4 + c.Length,
-			List: []Code{
-				Code{Length: 1, Literal: "0"},
-				Code{Length: 1, Literal: fmt.Sprint(i)},
-				Code{Length: 1, Literal: "CODE.QUOTE"},
-				Code{Length: 1 + c.Length, List: []Code{
-					Code{Length: 1, Literal: "INTEGER.POP"},
-					c,
-				}},
-				Code{Length: 1, Literal: "CODE.DO*RANGE"},
-			},
-		}
+		toPush := newList([]Code{
+			Code{Length: 1, Literal: "0"},
+			Code{Length: 1, Literal: fmt.Sprint(i)},
+			Code{Length: 1, Literal: "CODE.QUOTE"},
+			newList([]Code{
+				Code{Length: 1, Literal: "INTEGER.POP"},
+				c,
+			}),
+			Code{Length: 1, Literal: "CODE.DO*RANGE"},
+		})
 
 		interpreter.Stacks["code"].Push(toPush)
 	}
@@ -316,7 +300,15 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 	}
 
 	s.Functions["extract"] = func() {
-		// TODO
+		if !interpreter.StackOK("code", 1) || !interpreter.StackOK("integer", 1) {
+			return
+		}
+
+		i := interpreter.Stacks["integer"].Pop().(int64)
+		c := interpreter.Stacks["code"].Pop().(Code)
+
+		sub, _ := c.At(wrapIndex(i, c.Points()))
+		interpreter.Stacks["code"].Push(sub)
 	}
 
 	s.Functions["flush"] = func() {
@@ -380,14 +372,26 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 	}
 
 	s.Functions["insert"] = func() {
-		// TODO
+		if !interpreter.StackOK("code", 2) || !interpreter.StackOK("integer", 1) {
+			return
+		}
+
+		i := interpreter.Stacks["integer"].Pop().(int64)
+		c1 := interpreter.Stacks["code"].Pop().(Code)
+		c2 := interpreter.Stacks["code"].Pop().(Code)
+
+		inserted := c1.Replace(wrapIndex(i, c1.Points()), c2)
+
+		if inserted.Length <= interpreter.Options.MaxPointsInProgram {
+			interpreter.Stacks["code"].Push(inserted)
+		}
 	}
 
 	s.Functions["instructions"] = func() {
 		c := Code{List: make([]Code, 0, len(interpreter.listOfInstructions))}
 
 		for _, instr := range interpreter.listOfInstructions {
-			if instr == "NAME-ERC" || instr == "FLOAT-ERC" || instr == "INTEGER-ERC" {
+			if interpreter.isERC(instr) {
 				continue
 			}
 
@@ -419,12 +423,7 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 		c1 := interpreter.Stacks["code"].Pop().(Code)
 		c2 := interpreter.Stacks["code"].Pop().(Code)
 
-		c := Code{
-			Length: c1.Length + c2.Length,
-			List:   []Code{c1, c2},
-		}
-
-		interpreter.Stacks["code"].Push(c)
+		interpreter.Stacks["code"].Push(newList([]Code{c1, c2}))
 	}
 
 	s.Functions["member"] = func() {
@@ -452,12 +451,7 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 			c = Code{Length: c.Length, List: []Code{c}}
 		}
 
-		idx := i % int64(len(c.List))
-		if idx < 0 {
-			idx = -idx
-		}
-
-		interpreter.Stacks["code"].Push(c.List[idx])
+		interpreter.Stacks["code"].Push(c.List[wrapIndex(i, len(c.List))])
 	}
 
 	s.Functions["nthcdr"] = func() {
@@ -477,17 +471,7 @@ func newCodeStack(interpreter *Interpreter) *Stack {
 			c = Code{Length: c.Length, List: []Code{c}}
 		}
 
-		idx := i % int64(len(c.List))
-		if idx < 0 {
-			idx = -idx
-		}
-
-		nthcdr := Code{List: c.List[idx:]}
-		for _, sl := range c.List {
-			nthcdr.Length += sl.Length
-		}
-
-		interpreter.Stacks["code"].Push(nthcdr)
+		interpreter.Stacks["code"].Push(newList(c.List[wrapIndex(i, len(c.List)):]))
 	}
 
 	s.Functions["null"] = func() {
@@ -2,7 +2,6 @@ package gopush
 
 import (
 	"fmt"
-	"reflect"
 )
 
 func newExecStack(interpreter *Interpreter) *Stack {
@@ -17,7 +16,7 @@ func newExecStack(interpreter *Interpreter) *Stack {
 
 		e1 := interpreter.Stacks["exec"].Pop().(Code)
 		e2 := interpreter.Stacks["exec"].Pop().(Code)
-		same := reflect.DeepEqual(e1, e2)
+		same := e1.Equal(e2)
 		interpreter.Stacks["boolean"].Push(same)
 	}
 
@@ -47,14 +46,12 @@ func newExecStack(interpreter *Interpreter) *Stack {
 			return
 		}
 
-		toPush := Code{
-			Length: 3 + code.Length,
-			List: []Code{
-				Code{Length: 1, Literal: "0"},
-				Code{Length: 1, Literal: fmt.Sprint(count - 1)},
-				Code{Length: 1, Literal: "EXEC.DO*RANGE"},
-				code,
-			}}
+		toPush := newList([]Code{
+			Code{Length: 1, Literal: "0"},
+			Code{Length: 1, Literal: fmt.Sprint(count - 1)},
+			Code{Length: 1, Literal: "EXEC.DO*RANGE"},
+			code,
+		})
 
 		interpreter.Stacks["exec"].Push(toPush)
 	}
@@ -80,15 +77,12 @@ func newExecStack(interpreter *Interpreter) *Stack {
 				cur++
 			}
 
-			interpreter.Stacks["exec"].Push(Code{
-				Length: 3 + c.Length,
-				List: []Code{
-					Code{Length: 1, Literal: fmt.Sprint(cur)},
-					Code{Length: 1, Literal: fmt.Sprint(dst)},
-					Code{Length: 1, Literal: "EXEC.DO*RANGE"},
-					c,
-				},
-			})
+			interpreter.Stacks["exec"].Push(newList([]Code{
+				Code{Length: 1, Literal: fmt.Sprint(cur)},
+				Code{Length: 1, Literal: fmt.Sprint(dst)},
+				Code{Length: 1, Literal: "EXEC.DO*RANGE"},
+				c,
+			}))
 
 			interpreter.Stacks["exec"].Push(c)
 		}
@@ -110,22 +104,17 @@ func newExecStack(interpreter *Interpreter) *Stack {
 			return
 		}
 
-		loopBody := Code{
-			Length: 1 + code.Length,
-			List: []Code{
-				Code{Length: 1, Literal: "INTEGER.POP"},
-				code,
-			},
-		}
+		loopBody := newList([]Code{
+			Code{Length: 1, Literal: "INTEGER.POP"},
+			code,
+		})
 
-		toPush := Code{
-			Length: 3 + code.Length,
-			List: []Code{
-				Code{Length: 1, Literal: "0"},
-				Code{Length: 1, Literal: fmt.Sprint(count - 1)},
-				Code{Length: 1, Literal: "EXEC.DO*RANGE"},
-				loopBody,
-			}}
+		toPush := newList([]Code{
+			Code{Length: 1, Literal: "0"},
+			Code{Length: 1, Literal: fmt.Sprint(count - 1)},
+			Code{Length: 1, Literal: "EXEC.DO*RANGE"},
+			loopBody,
+		})
 
 		interpreter.Stacks["exec"].Push(toPush)
 	}
@@ -181,15 +170,7 @@ func newExecStack(interpreter *Interpreter) *Stack {
 		b := interpreter.Stacks["exec"].Pop().(Code)
 		c := interpreter.Stacks["exec"].Pop().(Code)
 
-		l := Code{
-			Length: b.Length + c.Length,
-			List: []Code{
-				b,
-				c,
-			},
-		}
-
-		interpreter.Stacks["exec"].Push(l)
+		interpreter.Stacks["exec"].Push(newList([]Code{b, c}))
 		interpreter.Stacks["exec"].Push(c)
 		interpreter.Stacks["exec"].Push(a)
 	}
@@ -228,7 +209,7 @@ func newExecStack(interpreter *Interpreter) *Stack {
 		}
 
 		e := interpreter.Stacks["exec"].Pop().(Code)
-		interpreter.Stacks["exec"].Push(Code{Length: 1 + e.Length, List: []Code{Code{Length: 1, Literal: "EXEC.Y"}, e}})
+		interpreter.Stacks["exec"].Push(newList([]Code{Code{Length: 1, Literal: "EXEC.Y"}, e}))
 		interpreter.Stacks["exec"].Push(e)
 	}
 
@@ -0,0 +1,157 @@
+package gopush
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OptionsError lists the problems found by Options.Validate.
+type OptionsError struct {
+	Problems []string
+}
+
+func (e *OptionsError) Error() string {
+	return "invalid options: " + strings.Join(e.Problems, "; ")
+}
+
+// BuiltinInstructions returns the names of the instructions of the builtin
+// types, keyed by type. The names are lowercase and do not include the type,
+// e.g. "+" for INTEGER.+. The result can be extended with the instructions of
+// custom stacks and passed to Options.Validate.
+func BuiltinInstructions() map[string][]string {
+	stacks := map[string]*Stack{
+		"boolean": newBooleanStack(nil),
+		"code":    newCodeStack(nil),
+		"exec":    newExecStack(nil),
+		"float":   newFloatStack(nil),
+		"integer": newIntStack(nil),
+		"name":    newNameStack(nil),
+	}
+
+	registry := make(map[string][]string)
+	for name, s := range stacks {
+		registry[name] = StackInstructions(s)
+	}
+
+	return registry
+}
+
+// StackInstructions returns the sorted names of the instructions of the given
+// stack, for use with Options.Validate.
+func StackInstructions(s *Stack) []string {
+	names := make([]string, 0, len(s.Functions))
+	for name := range s.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Validate checks the allowed types and instructions against the given
+// registry, which maps every known type to the names of its instructions (see
+// BuiltinInstructions). If registry is nil, the builtin instructions are used.
+// It reports instructions that do not exist, instructions of types that are
+// not allowed, weights for instructions that are not allowed, and allowed
+// types without any allowed instructions. The NAME and EXEC types always exist
+// and need not be allowed explicitly. All problems are returned together as an
+// *OptionsError.
+func (o Options) Validate(registry map[string][]string) error {
+	if registry == nil {
+		registry = BuiltinInstructions()
+	}
+
+	known := make(map[string]struct{})
+	for t, instructions := range registry {
+		for _, instr := range instructions {
+			known[t+"."+strings.ToLower(instr)] = struct{}{}
+		}
+	}
+
+	var problems []string
+	used := make(map[string]bool)
+
+	for _, instr := range sortedKeys(o.AllowedInstructions) {
+		t := instr
+		if idx := strings.Index(instr, "."); idx >= 0 {
+			t = instr[:idx]
+		}
+
+		if _, ok := known[instr]; !ok {
+			problem := fmt.Sprintf("unknown instruction %v", strings.ToUpper(instr))
+			if suggestion := closestInstruction(instr, known); suggestion != "" {
+				problem += fmt.Sprintf(" (did you mean %v?)", strings.ToUpper(suggestion))
+			}
+			problems = append(problems, problem)
+			continue
+		}
+
+		if _, ok := o.AllowedTypes[t]; !ok && t != "name" && t != "exec" {
+			problems = append(problems, fmt.Sprintf("instruction %v requires type %v, which is not allowed", strings.ToUpper(instr), strings.ToUpper(t)))
+			continue
+		}
+
+		used[t] = true
+	}
+
+	weighted := make([]string, 0, len(o.InstructionWeights))
+	for name := range o.InstructionWeights {
+		weighted = append(weighted, name)
+	}
+	sort.Strings(weighted)
+
+	for _, name := range weighted {
+		if _, ok := o.AllowedInstructions[name]; !ok && !strings.HasSuffix(name, "-erc") {
+			problems = append(problems, fmt.Sprintf("instruction %v has a weight but is not allowed", strings.ToUpper(name)))
+		}
+	}
+
+	for _, t := range sortedKeys(o.AllowedTypes) {
+		if t != "name" && t != "exec" && !used[t] {
+			problems = append(problems, fmt.Sprintf("type %v has no allowed instructions", strings.ToUpper(t)))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &OptionsError{Problems: problems}
+	}
+
+	return nil
+}
+
+// closestInstruction returns the known instruction of the same type that is
+// closest to instr, if at most a third of the characters of its name differ.
+func closestInstruction(instr string, known map[string]struct{}) string {
+	parts := strings.SplitN(instr, ".", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+
+	best, bestDistance := "", len(parts[1])/3+1
+
+	for candidate := range known {
+		if !strings.HasPrefix(candidate, parts[0]+".") {
+			continue
+		}
+
+		d := levenshtein(parts[1], candidate[len(parts[0])+1:])
+		if d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+
+	return best
+}
+
+// NewStrictInterpreter is like NewInterpreter, but returns an error if the
+// options do not pass Validate with the builtin instructions. Options that
+// allow instructions of custom stacks must be validated with a registry that
+// includes them instead.
+func NewStrictInterpreter(options Options) (*Interpreter, error) {
+	if err := options.Validate(nil); err != nil {
+		return nil, err
+	}
+
+	return NewInterpreter(options), nil
+}
@@ -0,0 +1,56 @@
+package gopush_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+func TestValidateOptions(t *testing.T) {
+	valid, _ := gopush.ParseOptions("type integer\ninstruction integer.*\ninstruction name.dup\ninstruction exec.dup")
+	if err := valid.Validate(nil); err != nil {
+		t.Errorf("expected %v to be valid, got %v", valid, err)
+	}
+
+	o, _ := gopush.ParseOptions("type integer\ntype boolean\ninstruction integer.plus\ninstruction integer.dupp\ninstruction float.+\ninstruction name.dup")
+
+	err := o.Validate(nil)
+
+	expected := []string{
+		"instruction FLOAT.+ requires type FLOAT, which is not allowed",
+		"unknown instruction INTEGER.DUPP (did you mean INTEGER.DUP?)",
+		"unknown instruction INTEGER.PLUS",
+		"type BOOLEAN has no allowed instructions",
+		"type INTEGER has no allowed instructions",
+	}
+
+	oe, ok := err.(*gopush.OptionsError)
+	if !ok {
+		t.Fatalf("expected an *OptionsError, got %v", err)
+	}
+
+	if !reflect.DeepEqual(oe.Problems, expected) {
+		t.Errorf("expected problems %q, got %q", expected, oe.Problems)
+	}
+
+	if _, err := gopush.NewStrictInterpreter(o); err == nil {
+		t.Error("expected NewStrictInterpreter to reject the options")
+	}
+}
+
+func TestValidateCustomStacks(t *testing.T) {
+	o, _ := gopush.ParseOptions("instruction string.dup")
+	o.AllowedTypes["string"] = struct{}{}
+
+	registry := gopush.BuiltinInstructions()
+	registry["string"] = gopush.StackInstructions(&gopush.Stack{Functions: map[string]func(){"dup": func() {}}})
+
+	if err := o.Validate(registry); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := o.Validate(nil); err == nil {
+		t.Error("expected STRING.DUP to be unknown without the custom stack")
+	}
+}
@@ -0,0 +1,107 @@
+package gopush
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SubtreeMutation replaces a randomly chosen point of c with a randomly
+// generated expression of at most MaxPointsInRandomExpression points. If the
+// result would exceed MaxPointsInProgram, c is returned unchanged.
+func (i *Interpreter) SubtreeMutation(c Code) Code {
+	point := i.Rand.Intn(c.Points())
+	subtree := i.RandomCode(i.Options.MaxPointsInRandomExpression)
+
+	return i.limitPoints(c, c.Replace(point, subtree))
+}
+
+// SubtreeCrossover replaces a randomly chosen point of c1 with a randomly
+// chosen subtree of c2. If the result would exceed MaxPointsInProgram, c1 is
+// returned unchanged.
+func (i *Interpreter) SubtreeCrossover(c1, c2 Code) Code {
+	point := i.Rand.Intn(c1.Points())
+	subtree, _ := c2.At(i.Rand.Intn(c2.Points()))
+
+	return i.limitPoints(c1, c1.Replace(point, subtree))
+}
+
+// PointMutation replaces every atom of c with a random instruction (or
+// ephemeral random constant) with the given probability.
+func (i *Interpreter) PointMutation(c Code, rate float64) Code {
+	return c.Map(func(atom Code) []Code {
+		if i.Rand.Float64() < rate {
+			return []Code{i.randomInstruction()}
+		}
+		return []Code{atom}
+	})
+}
+
+// UniformAddition inserts a random instruction (or ephemeral random constant)
+// directly before or after every atom of c with the given probability. If the
+// result would exceed MaxPointsInProgram, c is returned unchanged.
+func (i *Interpreter) UniformAddition(c Code, rate float64) Code {
+	mutated := c.Map(func(atom Code) []Code {
+		if i.Rand.Float64() >= rate {
+			return []Code{atom}
+		}
+
+		if i.Rand.Intn(2) == 0 {
+			return []Code{i.randomInstruction(), atom}
+		}
+
+		return []Code{atom, i.randomInstruction()}
+	})
+
+	return i.limitPoints(c, mutated)
+}
+
+// UniformDeletion removes every atom of c with the given probability.
+func (i *Interpreter) UniformDeletion(c Code, rate float64) Code {
+	return c.Map(func(atom Code) []Code {
+		if i.Rand.Float64() < rate {
+			return nil
+		}
+		return []Code{atom}
+	})
+}
+
+// PerturbConstants adds Gaussian noise with the given standard deviation to
+// every INTEGER and FLOAT literal in c with the given probability. The noise
+// added to INTEGER literals is rounded to the nearest integer.
+func (i *Interpreter) PerturbConstants(c Code, rate float64, stddev float64) Code {
+	return c.Map(func(atom Code) []Code {
+		if intlit, err := strconv.ParseInt(atom.Literal, 10, 64); err == nil {
+			if i.Rand.Float64() < rate {
+				intlit += int64(math.Floor(i.Rand.NormFloat64()*stddev + 0.5))
+				atom = Code{Length: 1, Literal: fmt.Sprint(intlit)}
+			}
+			return []Code{atom}
+		}
+
+		if floatlit, err := strconv.ParseFloat(atom.Literal, 64); err == nil {
+			if i.Rand.Float64() < rate {
+				floatlit += i.Rand.NormFloat64() * stddev
+				l := fmt.Sprint(floatlit)
+				if !strings.Contains(l, ".") {
+					l += ".0"
+				}
+				atom = Code{Length: 1, Literal: l}
+			}
+			return []Code{atom}
+		}
+
+		return []Code{atom}
+	})
+}
+
+// limitPoints returns mutated if it does not exceed MaxPointsInProgram, and
+// original otherwise.
+func (i *Interpreter) limitPoints(original, mutated Code) Code {
+	if mutated.Length > i.Options.MaxPointsInProgram {
+		return original
+	}
+
+	return mutated
+}
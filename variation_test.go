@@ -0,0 +1,94 @@
+package gopush_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+func newVariationInterpreter() *gopush.Interpreter {
+	options := gopush.DefaultOptions
+	options.RandomSeed = 1138
+	options.MaxPointsInProgram = 20
+	return gopush.NewInterpreter(options)
+}
+
+func mustParse(t *testing.T, program string) gopush.Code {
+	c, err := gopush.ParseCode(program)
+	if err != nil {
+		t.Fatalf("unexpected error while parsing %q: %v", program, err)
+	}
+	return c
+}
+
+// Checks that the Length of c matches what ParseCode produces for its textual
+// representation
+func checkLength(t *testing.T, c gopush.Code) {
+	reparsed := mustParse(t, c.String()).List[0]
+
+	if c.Length != reparsed.Length {
+		t.Errorf("expected %v to have length %v, got %v", c, reparsed.Length, c.Length)
+	}
+}
+
+func TestVariationRespectsMaxPointsInProgram(t *testing.T) {
+	interpreter := newVariationInterpreter()
+	c1 := mustParse(t, "1 ( 2 INTEGER.+ ) ( A ( B C ) ) 3.5")
+	c2 := mustParse(t, "( INTEGER.DUP ( INTEGER.* 4 ) ) FLOAT.SIN")
+
+	for n := 0; n < 200; n++ {
+		children := []gopush.Code{
+			interpreter.SubtreeMutation(c1),
+			interpreter.SubtreeCrossover(c1, c2),
+			interpreter.UniformAddition(c1, 0.5),
+		}
+
+		for _, child := range children {
+			if child.Length > interpreter.Options.MaxPointsInProgram {
+				t.Fatalf("expected child %v to respect MaxPointsInProgram", child)
+			}
+			checkLength(t, child)
+		}
+	}
+}
+
+func TestPointMutationAndDeletion(t *testing.T) {
+	interpreter := newVariationInterpreter()
+	c := mustParse(t, "1 ( 2 INTEGER.+ ) ( A ( B C ) )")
+
+	if m := interpreter.PointMutation(c, 0); !reflect.DeepEqual(m, c) {
+		t.Errorf("expected point mutation with rate 0 to leave %v unchanged, got %v", c, m)
+	}
+
+	m := interpreter.PointMutation(c, 1)
+	if m.Length != c.Length || m.String() == c.String() {
+		t.Errorf("expected point mutation with rate 1 to change all atoms of %v, got %v", c, m)
+	}
+
+	d := interpreter.UniformDeletion(c, 1)
+	if d.String() != "( ( ) ( ( ) ) )" {
+		t.Errorf("expected uniform deletion with rate 1 to remove all atoms, got %v", d)
+	}
+	checkLength(t, d)
+}
+
+func TestPerturbConstants(t *testing.T) {
+	interpreter := newVariationInterpreter()
+	c := mustParse(t, "5 2.5 INTEGER.+ TRUE")
+
+	p := interpreter.PerturbConstants(c, 1, 10)
+
+	if p.List[2].Literal != "INTEGER.+" || p.List[3].Literal != "TRUE" {
+		t.Errorf("expected non-numeric literals to be left alone, got %v", p)
+	}
+
+	if _, err := strconv.ParseInt(p.List[0].Literal, 10, 64); err != nil {
+		t.Errorf("expected perturbed integer to be an integer, got %q", p.List[0].Literal)
+	}
+
+	if p.List[1].Literal == "2.5" {
+		t.Errorf("expected float literal to be perturbed, got %v", p)
+	}
+}
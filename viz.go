@@ -0,0 +1,287 @@
+package gopush
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// VizOptions control how WriteDOT and WriteSVG render Code.
+type VizOptions struct {
+	// Title is written above the tree if it is not empty
+	Title string
+
+	// Colors maps the names of stacks to the fill colors of their
+	// instructions and literals. Names use the color of the name stack.
+	// Items of stacks without a color are drawn white.
+	Colors map[string]string
+}
+
+// DefaultVizColors are the colors used by gopush viz.
+var DefaultVizColors = map[string]string{
+	"boolean": "#fb9a99",
+	"code":    "#fdbf6f",
+	"exec":    "#cab2d6",
+	"float":   "#b2df8a",
+	"integer": "#a6cee3",
+	"name":    "#ffff99",
+}
+
+// DefaultVizOptions are the options used by gopush viz.
+var DefaultVizOptions = VizOptions{
+	Colors: DefaultVizColors,
+}
+
+// atomKind is what an atom does when it is executed.
+type atomKind int
+
+const (
+	instructionAtom atomKind = iota
+	literalAtom
+	nameAtom
+)
+
+// classifyAtom returns what the atom does when the interpreter executes it,
+// and the stack it belongs to. It follows the order of execute, so that e.g.
+// 1 is an INTEGER and not a FLOAT literal.
+func (i *Interpreter) classifyAtom(literal string) (atomKind, string) {
+	if _, err := strconv.ParseInt(literal, 10, 64); err == nil {
+		return literalAtom, "integer"
+	}
+
+	if _, err := strconv.ParseFloat(literal, 64); err == nil {
+		return literalAtom, "float"
+	}
+
+	if _, err := strconv.ParseBool(literal); err == nil {
+		return literalAtom, "boolean"
+	}
+
+	for _, name := range i.literalStacks {
+		if _, ok := i.Stacks[name].ParseLiteral(literal); ok {
+			return literalAtom, name
+		}
+	}
+
+	if strings.Contains(literal, ".") {
+		return instructionAtom, strings.ToLower(literal[:strings.Index(literal, ".")])
+	}
+
+	return nameAtom, "name"
+}
+
+// vizNode is the style of a point in the rendered tree.
+type vizNode struct {
+	label string
+	kind  atomKind
+	fill  string
+	list  bool
+}
+
+func (i *Interpreter) vizNode(c Code, o VizOptions) vizNode {
+	if c.Literal == "" {
+		return vizNode{list: true}
+	}
+
+	kind, stack := i.classifyAtom(c.Literal)
+
+	fill, ok := o.Colors[stack]
+	if !ok {
+		fill = "#ffffff"
+	}
+
+	return vizNode{label: c.Literal, kind: kind, fill: fill}
+}
+
+// WriteDOT writes c as a Graphviz graph to w. Lists are drawn as small dots,
+// instructions as boxes, literals as rounded boxes with a bold border and names
+// as ellipses, filled with the color of their stack. The children of a list are
+// kept in program order.
+func (i *Interpreter) WriteDOT(w io.Writer, c Code, o VizOptions) error {
+	var b strings.Builder
+
+	b.WriteString("digraph program {\n")
+	b.WriteString("\tgraph [ordering=out")
+	if o.Title != "" {
+		fmt.Fprintf(&b, ", label=%s, labelloc=t", dotQuote(o.Title))
+	}
+	b.WriteString("];\n")
+	b.WriteString("\tnode [fontname=\"Helvetica\", style=filled];\n")
+
+	point := 0
+	var walk func(c Code) int
+	walk = func(c Code) int {
+		id := point
+		point++
+
+		n := i.vizNode(c, o)
+		switch {
+		case n.list:
+			fmt.Fprintf(&b, "\tn%d [shape=point, width=0.12, fillcolor=\"#808080\"];\n", id)
+		case n.kind == instructionAtom:
+			fmt.Fprintf(&b, "\tn%d [label=%s, shape=box, fillcolor=%s];\n", id, dotQuote(n.label), dotQuote(n.fill))
+		case n.kind == literalAtom:
+			fmt.Fprintf(&b, "\tn%d [label=%s, shape=box, style=\"rounded,filled,bold\", fillcolor=%s];\n", id, dotQuote(n.label), dotQuote(n.fill))
+		default:
+			fmt.Fprintf(&b, "\tn%d [label=%s, shape=ellipse, fillcolor=%s];\n", id, dotQuote(n.label), dotQuote(n.fill))
+		}
+
+		for _, sl := range c.List {
+			fmt.Fprintf(&b, "\tn%d -> n%d;\n", id, walk(sl))
+		}
+
+		return id
+	}
+	walk(c)
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote returns s as a quoted DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// Layout of the SVG rendering, in pixels
+const (
+	svgCharWidth  = 8
+	svgNodeHeight = 24
+	svgLevelGap   = 48
+	svgNodeGap    = 12
+	svgMargin     = 16
+	svgTitle      = 28
+	svgDotRadius  = 5
+)
+
+// svgLayout is the position of a point in the SVG rendering.
+type svgLayout struct {
+	node     vizNode
+	x, y     int // center
+	width    int // of the node itself
+	children []*svgLayout
+}
+
+// WriteSVG writes c as a standalone SVG image of the same tree as WriteDOT to
+// w. Unlike WriteDOT, it does not need Graphviz: leaves are placed from left
+// to right in program order, and every list is centered above its items.
+func (i *Interpreter) WriteSVG(w io.Writer, c Code, o VizOptions) error {
+	top := svgMargin
+	if o.Title != "" {
+		top += svgTitle
+	}
+
+	root, width, depth := i.svgLayout(c, o, svgMargin, top)
+	width += 2 * svgMargin
+	if titleWidth := utf8.RuneCountInString(o.Title)*svgCharWidth + 2*svgMargin; o.Title != "" && titleWidth > width {
+		width = titleWidth
+	}
+	height := top + depth*(svgNodeHeight+svgLevelGap) - svgLevelGap + svgMargin
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" font-family=\"Helvetica, sans-serif\" font-size=\"13\">\n", width, height, width, height)
+	if o.Title != "" {
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\" font-size=\"16\">%s</text>\n", width/2, svgMargin+svgTitle/2, html.EscapeString(o.Title))
+	}
+
+	// Edges first, so that the nodes are drawn on top of them
+	var edges, nodes func(l *svgLayout)
+	edges = func(l *svgLayout) {
+		for _, child := range l.children {
+			fmt.Fprintf(&b, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"#808080\"/>\n", l.x, l.y, child.x, child.y)
+			edges(child)
+		}
+	}
+	nodes = func(l *svgLayout) {
+		n := l.node
+		x, y := l.x-l.width/2, l.y-svgNodeHeight/2
+
+		switch {
+		case n.list:
+			fmt.Fprintf(&b, "<circle cx=\"%d\" cy=\"%d\" r=\"%d\" fill=\"#808080\"/>\n", l.x, l.y, svgDotRadius)
+		case n.kind == instructionAtom:
+			fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\" stroke=\"black\"/>\n", x, y, l.width, svgNodeHeight, html.EscapeString(n.fill))
+		case n.kind == literalAtom:
+			fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"8\" fill=\"%s\" stroke=\"black\" stroke-width=\"2\"/>\n", x, y, l.width, svgNodeHeight, html.EscapeString(n.fill))
+		default:
+			fmt.Fprintf(&b, "<ellipse cx=\"%d\" cy=\"%d\" rx=\"%d\" ry=\"%d\" fill=\"%s\" stroke=\"black\"/>\n", l.x, l.y, l.width/2, svgNodeHeight/2, html.EscapeString(n.fill))
+		}
+
+		if !n.list {
+			fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\" dominant-baseline=\"central\">%s</text>\n", l.x, l.y, html.EscapeString(n.label))
+		}
+
+		for _, child := range l.children {
+			nodes(child)
+		}
+	}
+	edges(root)
+	nodes(root)
+
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// svgLayout places c in the area starting at left and top, and returns its
+// layout together with the width and the depth of the area it takes up.
+func (i *Interpreter) svgLayout(c Code, o VizOptions, left, top int) (*svgLayout, int, int) {
+	l := &svgLayout{node: i.vizNode(c, o), y: top + svgNodeHeight/2}
+
+	l.width = 2 * svgDotRadius
+	if !l.node.list {
+		l.width = utf8.RuneCountInString(l.node.label)*svgCharWidth + svgNodeGap
+	}
+
+	childrenWidth, depth := 0, 0
+	for j, sl := range c.List {
+		if j > 0 {
+			childrenWidth += svgNodeGap
+		}
+
+		child, width, d := i.svgLayout(sl, o, left+childrenWidth, top+svgNodeHeight+svgLevelGap)
+		l.children = append(l.children, child)
+		childrenWidth += width
+		if d > depth {
+			depth = d
+		}
+	}
+
+	width := l.width
+	if childrenWidth > width {
+		width = childrenWidth
+	} else {
+		// Center the items below a node that is wider than they are
+		shiftSVGLayout(l.children, (width-childrenWidth)/2)
+	}
+
+	l.x = left + width/2
+	if len(l.children) > 0 {
+		l.x = (l.children[0].x + l.children[len(l.children)-1].x) / 2
+	}
+
+	// Keep the node itself inside its area
+	if l.x-l.width/2 < left {
+		l.x = left + l.width/2
+	}
+	if l.x+l.width/2 > left+width {
+		l.x = left + width - l.width/2
+	}
+
+	return l, width, depth + 1
+}
+
+// shiftSVGLayout moves the given subtrees dx pixels to the right.
+func shiftSVGLayout(layouts []*svgLayout, dx int) {
+	for _, l := range layouts {
+		l.x += dx
+		shiftSVGLayout(l.children, dx)
+	}
+}
@@ -0,0 +1,105 @@
+package gopush_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/DataWraith/gopush"
+)
+
+// Tests that WriteDOT draws every point in program order, styled and colored by
+// what it does, and quotes the title
+func TestWriteDOT(t *testing.T) {
+	interpreter := gopush.NewInterpreter(gopush.DefaultOptions)
+	c := mustParse(t, "1 ( 2.5 INTEGER.+ ) FOO")
+
+	var buf bytes.Buffer
+	if err := interpreter.WriteDOT(&buf, c, gopush.VizOptions{Title: `say "hi"`, Colors: gopush.DefaultVizColors}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `digraph program {
+	graph [ordering=out, label="say \"hi\"", labelloc=t];
+	node [fontname="Helvetica", style=filled];
+	n0 [shape=point, width=0.12, fillcolor="#808080"];
+	n1 [label="1", shape=box, style="rounded,filled,bold", fillcolor="#a6cee3"];
+	n0 -> n1;
+	n2 [shape=point, width=0.12, fillcolor="#808080"];
+	n3 [label="2.5", shape=box, style="rounded,filled,bold", fillcolor="#b2df8a"];
+	n2 -> n3;
+	n4 [label="INTEGER.+", shape=box, fillcolor="#a6cee3"];
+	n2 -> n4;
+	n0 -> n2;
+	n5 [label="FOO", shape=ellipse, fillcolor="#ffff99"];
+	n0 -> n5;
+}
+`
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
+
+// Tests that WriteSVG produces well-formed SVG with a node for every point and
+// an escaped title
+func TestWriteSVG(t *testing.T) {
+	interpreter := gopush.NewInterpreter(gopush.DefaultOptions)
+
+	for _, program := range []string{"", "A", "1 ( 2.5 INTEGER.+ ) FOO", "( ( ( ) ) ) CODE.QUOTE ( A < B & C )"} {
+		var buf bytes.Buffer
+		if err := interpreter.WriteSVG(&buf, mustParse(t, program), gopush.DefaultVizOptions); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// The result must be well-formed XML with one node per point
+		nodes := 0
+		d := xml.NewDecoder(&buf)
+		for {
+			token, err := d.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("invalid SVG for %q: %v", program, err)
+			}
+
+			if start, ok := token.(xml.StartElement); ok {
+				switch start.Name.Local {
+				case "circle", "rect", "ellipse":
+					nodes++
+				}
+			}
+		}
+
+		points := mustParse(t, program).Points()
+		if nodes != points {
+			t.Errorf("expected %v nodes for %q, got %v", points, program, nodes)
+		}
+	}
+
+	// Widths are measured in characters, not bytes
+	for _, tt := range []struct {
+		program, title string
+	}{
+		{"ÄÖÜ", ""},
+		{"A", "Überprüfung"},
+	} {
+		var ascii, unicode bytes.Buffer
+		interpreter.WriteSVG(&ascii, mustParse(t, "AAA"), gopush.VizOptions{Title: strings.Repeat("x", len([]rune(tt.title)))})
+		interpreter.WriteSVG(&unicode, mustParse(t, tt.program), gopush.VizOptions{Title: tt.title})
+
+		// The first line holds the size of the image
+		expected := strings.SplitN(ascii.String(), "\n", 2)[0]
+		if got := strings.SplitN(unicode.String(), "\n", 2)[0]; got != expected {
+			t.Errorf("expected %q with title %q to be as wide as its ASCII equivalent %s, got %s", tt.program, tt.title, expected, got)
+		}
+	}
+
+	var buf bytes.Buffer
+	interpreter.WriteSVG(&buf, mustParse(t, "A"), gopush.VizOptions{Title: "<A>"})
+	if !strings.Contains(buf.String(), ">&lt;A&gt;</text>") {
+		t.Errorf("expected the title to be escaped, got %s", buf.String())
+	}
+}